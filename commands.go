@@ -0,0 +1,474 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"meetkat/internal/archive"
+	"meetkat/internal/config"
+	"meetkat/internal/poll"
+	"meetkat/internal/secret"
+	"meetkat/internal/sqlite"
+)
+
+const usage = `Usage: meetkat [flags] [command]
+
+Without a command, meetkat starts the web server.
+
+Commands:
+  backup <path>    write a consistent copy of the database to <path>
+  restore <path>   replace the database with the backup at <path> (server must be stopped)
+  export <path> [poll-id...]
+                   write polls (default: all) to a JSON archive; "-" writes to stdout
+  import [-on-conflict=skip|rename|fail] [-reissue-admin-links] <path>
+                   load polls from a JSON archive; "-" reads from stdin;
+                   -reissue-admin-links gives polls that carry only a hashed
+                   admin link a new one
+  healthcheck [-live] [-url=URL]
+                   exit 0 if the local server is ready (or alive with -live)
+  migrate status   list schema migrations and whether they are applied
+  migrate up [-dry-run]
+                   apply pending migrations
+  migrate down [-dry-run] [-steps=N] [-yes]
+                   revert the N most recent migrations (default 1); -yes confirms
+                   reverts that destroy data
+  admin-link <poll-id>
+                   give a poll a new admin link and print it; the old link stops working
+  config print     write the effective configuration as a TOML config file
+
+`
+
+// runCommand executes a CLI subcommand and returns the process exit code.
+func runCommand(cfg config.Config, name string, args []string) int {
+	var err error
+	switch name {
+	case "backup":
+		err = runBackup(cfg, args)
+	case "restore":
+		err = runRestore(cfg, args)
+	case "export":
+		err = runExport(cfg, args)
+	case "import":
+		err = runImport(cfg, args)
+	case "migrate":
+		err = runMigrate(cfg, args)
+	case "healthcheck":
+		err = runHealthcheck(cfg, args)
+	case "admin-link":
+		err = runAdminLink(cfg, args)
+	case "config":
+		err = runConfig(cfg, args)
+	case "help":
+		fmt.Print(usage)
+		config.Usage(os.Stdout)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+		config.Usage(os.Stderr)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+func runBackup(cfg config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one destination path")
+	}
+	if _, err := os.Stat(cfg.DBPath); err != nil {
+		return fmt.Errorf("database %s: %w", cfg.DBPath, err)
+	}
+	db, err := sqlite.Connect(cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	if err := sqlite.Backup(db, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("backup written to %s\n", args[0])
+	return nil
+}
+
+func runRestore(cfg config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one backup path")
+	}
+	if err := sqlite.Restore(args[0], cfg.DBPath); err != nil {
+		return err
+	}
+	fmt.Printf("restored %s from %s\n", cfg.DBPath, args[0])
+	return nil
+}
+
+// loadSecret reads the instance secret key, creating it on first use.
+func loadSecret(cfg config.Config) ([]byte, error) {
+	return secret.Load(cfg.SecretKeyPath())
+}
+
+// adminKey derives the key admin IDs are hashed with from the secret key.
+func adminKey(key []byte) []byte {
+	return secret.Derive(key, "admin-id")
+}
+
+// openDatabase opens the configured database and, depending on
+// cfg.AutoMigrate, either applies pending migrations or refuses to continue
+// while there are any. key is the secret key, which migrations need to hash
+// existing admin IDs.
+func openDatabase(cfg config.Config, key []byte) (*sql.DB, error) {
+	db, err := sqlite.Connect(cfg.DBPath)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator := sqlite.NewMigrator(db, adminKey(key))
+	if cfg.AutoMigrate {
+		applied, err := migrator.Up(false)
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+		if len(applied) > 0 {
+			slog.Info("applied migrations", "versions", applied)
+		}
+	} else if err := migrator.Check(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf(`%w (run "meetkat migrate up" or set MEETKAT_AUTO_MIGRATE=true)`, err)
+	}
+	return db, nil
+}
+
+// openService opens the configured database and returns a poll service on
+// top of it together with a function that closes the database.
+func openService(cfg config.Config) (*poll.Service, func(), error) {
+	if _, err := os.Stat(cfg.DBPath); err != nil {
+		return nil, nil, fmt.Errorf("database %s: %w", cfg.DBPath, err)
+	}
+	key, err := loadSecret(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := openDatabase(cfg, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newService(cfg, sqlite.NewPollRepository(db), key), func() { _ = db.Close() }, nil
+}
+
+// newService creates a poll service with the configured input limits that
+// hashes admin IDs with a key derived from the secret key.
+func newService(cfg config.Config, repo poll.Repository, key []byte) *poll.Service {
+	svc := poll.NewService(repo)
+	svc.SetAdminKey(adminKey(key))
+	svc.SetLimits(poll.Limits{
+		MaxTitleLen:       cfg.MaxTitleLen,
+		MaxDescriptionLen: cfg.MaxDescriptionLen,
+		MaxNameLen:        cfg.MaxNameLen,
+		MaxOptions:        cfg.MaxOptions,
+	})
+	return svc
+}
+
+func runAdminLink(cfg config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one poll ID")
+	}
+	svc, closeDB, err := openService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	adminID, err := svc.ResetAdminID(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("new admin link for poll %s: %s\n", args[0], adminLink(cfg, adminID))
+	return nil
+}
+
+// adminLink returns the admin link for adminID, absolute if base_url is set.
+func adminLink(cfg config.Config, adminID string) string {
+	if cfg.BaseURL != "" {
+		return cfg.BaseURL + "/poll/" + adminID + "/admin"
+	}
+	return cfg.BasePath + "/poll/" + adminID + "/admin"
+}
+
+func runConfig(cfg config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return fmt.Errorf(`expected "config print"`)
+	}
+	return cfg.WriteTOML(os.Stdout)
+}
+
+func runExport(cfg config.Config, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("expected a destination path")
+	}
+	svc, closeDB, err := openService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	a, err := archive.Export(context.Background(), svc, args[1:], time.Now())
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if args[0] != "-" {
+		f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		w = f
+	}
+	if err := archive.Write(w, a); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	if args[0] != "-" {
+		fmt.Printf("exported %d polls to %s\n", len(a.Polls), args[0])
+	}
+	return nil
+}
+
+func runImport(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	onConflict := fs.String("on-conflict", poll.ImportSkip, "what to do when a poll ID already exists: skip, rename or fail")
+	reissue := fs.Bool("reissue-admin-links", false, "give polls imported with only an admin hash a new admin link")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected exactly one archive path")
+	}
+
+	var r io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+	a, err := archive.Read(r)
+	if err != nil {
+		return err
+	}
+
+	svc, closeDB, err := openService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	results, err := archive.Import(ctx, svc, a, *onConflict)
+	imported, skipped := 0, 0
+	// Polls exported by "meetkat export" carry only the hash of their admin
+	// ID, which matches the admin link only if this instance has the same
+	// secret key as the exporting one.
+	var hashOnly []string
+	for _, res := range results {
+		switch {
+		case res.Poll == nil:
+			skipped++
+			fmt.Printf("skipped %s: id already exists\n", res.OriginalID)
+		case res.Renamed():
+			imported++
+			fmt.Printf("imported %s as %s (admin id %s)\n", res.OriginalID, res.Poll.ID, res.Poll.AdminID)
+		default:
+			imported++
+			if res.Poll.AdminID == "" {
+				hashOnly = append(hashOnly, res.Poll.ID)
+			}
+		}
+	}
+	fmt.Printf("imported %d polls, skipped %d\n", imported, skipped)
+
+	switch {
+	case len(hashOnly) == 0:
+	case *reissue:
+		for _, id := range hashOnly {
+			adminID, rerr := svc.ResetAdminID(ctx, id)
+			if rerr != nil {
+				return errors.Join(err, fmt.Errorf("reissue admin link of %s: %w", id, rerr))
+			}
+			fmt.Printf("new admin link for poll %s: %s\n", id, adminLink(cfg, adminID))
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "warning: %d polls were imported with only a hashed admin link, which works only if the exporting instance used the same secret key: %s\n"+
+			"give them new links with \"meetkat admin-link <poll-id>\" (-reissue-admin-links does this during import)\n",
+			len(hashOnly), strings.Join(hashOnly, ", "))
+	}
+	return err
+}
+
+func runMigrate(cfg config.Config, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("expected status, up or down")
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "run the migrations in a transaction that is rolled back")
+	steps := fs.Int("steps", 1, "number of migrations to revert (down only)")
+	yes := fs.Bool("yes", false, "revert migrations whose down files warn of data loss (down only)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0o750); err != nil {
+		return fmt.Errorf("create data directory: %w", err)
+	}
+	key, err := loadSecret(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := sqlite.Connect(cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	migrator := sqlite.NewMigrator(db, adminKey(key))
+
+	var done []string
+	switch action {
+	case "status":
+		return printMigrationStatus(migrator)
+	case "up":
+		done, err = migrator.Up(*dryRun)
+	case "down":
+		if *steps < 1 {
+			return fmt.Errorf("-steps must be at least 1")
+		}
+		var warnings []string
+		warnings, err = migrator.DownWarnings(*steps)
+		if err != nil {
+			return err
+		}
+		for _, w := range warnings {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+		if len(warnings) > 0 && !*dryRun && !*yes {
+			return fmt.Errorf("reverting would destroy data; take a backup and run again with -yes")
+		}
+		done, err = migrator.Down(*steps, *dryRun)
+	default:
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+
+	verb := map[string]string{"up": "applied", "down": "reverted"}[action]
+	if *dryRun {
+		verb = "would be " + verb
+	}
+	for _, v := range done {
+		fmt.Printf("%s %s\n", verb, v)
+	}
+	if err != nil {
+		return err
+	}
+	if len(done) == 0 {
+		fmt.Println("nothing to do")
+	}
+	return nil
+}
+
+func printMigrationStatus(migrator *sqlite.Migrator) error {
+	states, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tSTATUS\tAPPLIED AT")
+	for _, st := range states {
+		status := "pending"
+		switch {
+		case st.Unknown:
+			status = "unknown"
+		case st.Modified:
+			status = "modified"
+		case st.Unverified:
+			status = "unverified"
+		case st.Applied:
+			status = "applied"
+		}
+		appliedAt := "-"
+		if !st.AppliedAt.IsZero() {
+			appliedAt = st.AppliedAt.Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", st.Version, status, appliedAt)
+	}
+	return w.Flush()
+}
+
+func runHealthcheck(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	live := fs.Bool("live", false, "check liveness (/healthz) instead of readiness (/readyz)")
+	url := fs.String("url", "", "probe this URL instead of the local server")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *url == "" {
+		path := "/readyz"
+		if *live {
+			path = "/healthz"
+		}
+		scheme := "http"
+		if cfg.TLSCert != "" {
+			scheme = "https"
+		}
+		host := "127.0.0.1:" + cfg.Port
+		if cfg.Port == "" {
+			host = "localhost"
+		}
+		*url = scheme + "://" + host + path
+	}
+
+	transport := &http.Transport{}
+	if cfg.TLSCert != "" {
+		// The certificate is issued for the public name, not 127.0.0.1; the
+		// probe only checks that the local server answers.
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // #nosec G402
+	}
+	if cfg.Port == "" && cfg.UnixSocket != "" {
+		// Without a TCP port, reach the server through its socket; the host
+		// in the URL is ignored.
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", cfg.UnixSocket)
+		}
+	}
+	client := &http.Client{Timeout: 5 * time.Second, Transport: transport}
+	resp, err := client.Get(*url)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", *url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"meetkat/internal/config"
+	"meetkat/internal/sqlite"
+)
+
+func testCommandConfig(t *testing.T) config.Config {
+	t.Helper()
+	cfg := config.Default()
+	cfg.DBPath = filepath.Join(t.TempDir(), "meetkat.db")
+	return cfg
+}
+
+// execSQL runs stmt directly on the database of cfg, bypassing migrations.
+func execSQL(t *testing.T, cfg config.Config, stmt string) {
+	t.Helper()
+	db, err := sqlite.Connect(cfg.DBPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(db *sql.DB) { _ = db.Close() }(db)
+	if _, err := db.Exec(stmt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateUpFailureExitsNonZero(t *testing.T) {
+	cfg := testCommandConfig(t)
+	// The first migration creates polls, which already exists.
+	execSQL(t, cfg, "CREATE TABLE polls (id TEXT)")
+
+	if code := runCommand(cfg, "migrate", []string{"up"}); code == 0 {
+		t.Error("migrate up exited 0 although a migration failed")
+	}
+}
+
+func TestMigrateDownFailureExitsNonZero(t *testing.T) {
+	cfg := testCommandConfig(t)
+	if code := runCommand(cfg, "migrate", []string{"up"}); code != 0 {
+		t.Fatalf("migrate up exited %d", code)
+	}
+	// The latest down migration drops a column of polls, which is gone.
+	execSQL(t, cfg, "DROP TABLE polls")
+
+	if code := runCommand(cfg, "migrate", []string{"down", "-yes"}); code == 0 {
+		t.Error("migrate down exited 0 although the migration failed")
+	}
+	if code := runCommand(cfg, "migrate", []string{"down", "-yes", "-dry-run"}); code == 0 {
+		t.Error("migrate down -dry-run exited 0 although the migration failed")
+	}
+}
+
+func TestMigrateDownRequiresYesForDataLoss(t *testing.T) {
+	cfg := testCommandConfig(t)
+	if code := runCommand(cfg, "migrate", []string{"up"}); code != 0 {
+		t.Fatalf("migrate up exited %d", code)
+	}
+	if code := runCommand(cfg, "migrate", []string{"down"}); code == 0 {
+		t.Error("migrate down reverted a lossy migration without -yes")
+	}
+	if code := runCommand(cfg, "migrate", []string{"down", "-yes"}); code != 0 {
+		t.Errorf("migrate down -yes exited %d", code)
+	}
+}
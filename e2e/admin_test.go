@@ -3,6 +3,7 @@
 package e2e
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -12,8 +13,8 @@ import (
 func TestAdminRemoveVote(t *testing.T) {
 	ts := startTestServer(t)
 	p := seedPoll(t, ts.Svc, "Remove Test", []string{"Mon", "Tue"})
-	_ = ts.Svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
-	_ = ts.Svc.AddVote(p.ID, "Bob", map[string]string{"Mon": "yes", "Tue": "yes"})
+	_ = ts.Svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
+	_ = ts.Svc.AddVote(context.Background(), p.ID, "Bob", map[string]string{"Mon": "yes", "Tue": "yes"})
 	ctx := newBrowserCtx(t)
 
 	var tableHTML string
@@ -43,7 +44,7 @@ func TestAdminRemoveVote(t *testing.T) {
 	}
 
 	// Verify server-side.
-	got, _ := ts.Svc.Get(p.ID)
+	got, _ := ts.Svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote, got %d", len(got.Votes))
 	}
@@ -55,7 +56,7 @@ func TestAdminRemoveVote(t *testing.T) {
 func TestAdminEditVote(t *testing.T) {
 	ts := startTestServer(t)
 	p := seedPoll(t, ts.Svc, "Edit Test", []string{"Mon", "Tue"})
-	_ = ts.Svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
+	_ = ts.Svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
 	ctx := newBrowserCtx(t)
 
 	var tableHTML string
@@ -110,7 +111,7 @@ func TestAdminEditVote(t *testing.T) {
 	}
 
 	// Verify server-side.
-	got, _ := ts.Svc.Get(p.ID)
+	got, _ := ts.Svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote, got %d", len(got.Votes))
 	}
@@ -85,18 +85,22 @@ func startTestServer(t *testing.T) *testServer {
 
 	r := gin.New()
 	r.Static("/static", "../web/static")
-	r.Use(middleware.LangCookie(tr))
+	r.Use(middleware.LangCookie(tr, middleware.DefaultCookieConfig()))
 
 	r.GET("/", hh.ShowHome)
 	r.GET("/new", ph.ShowNew)
 	r.POST("/new", ph.CreatePoll)
 	r.GET("/poll/:id", ph.ShowPoll)
 	r.POST("/poll/:id/vote", ph.SubmitVote)
+	r.POST("/poll/:id/unlock", ph.UnlockPoll)
 	r.GET("/poll/:id/admin", ph.ShowAdmin)
 	r.POST("/poll/:id/admin/vote", ph.SubmitAdminVote)
 	r.POST("/poll/:id/admin/remove", ph.RemoveVote)
 	r.POST("/poll/:id/admin/delete", ph.DeletePoll)
 	r.POST("/poll/:id/admin/edit", ph.UpdateVote)
+	r.POST("/poll/:id/admin/rotate", ph.RotateAdminLink)
+	r.POST("/poll/:id/admin/close", ph.ClosePoll)
+	r.GET("/poll/:id/admin/export", ph.ExportPoll)
 
 	srv := httptest.NewServer(r)
 	t.Cleanup(srv.Close)
@@ -107,7 +111,7 @@ func startTestServer(t *testing.T) *testServer {
 // seedPoll creates a poll via the service and returns it.
 func seedPoll(t *testing.T, svc *poll.Service, title string, options []string) *poll.Poll {
 	t.Helper()
-	p, err := svc.Create(title, "", "yn", options)
+	p, err := svc.Create(context.Background(), title, "", "yn", options, poll.Settings{})
 	if err != nil {
 		t.Fatalf("seedPoll: %v", err)
 	}
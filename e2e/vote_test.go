@@ -3,6 +3,7 @@
 package e2e
 
 import (
+	"context"
 	"testing"
 
 	"github.com/chromedp/chromedp"
@@ -102,7 +103,7 @@ func TestAJAXVoteSubmission(t *testing.T) {
 	}
 
 	// Verify the vote was persisted server-side.
-	got, _ := ts.Svc.Get(p.ID)
+	got, _ := ts.Svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote server-side, got %d", len(got.Votes))
 	}
@@ -147,7 +148,7 @@ func TestTwoClickConfirmIncomplete(t *testing.T) {
 	}
 
 	// Verify no vote was submitted yet.
-	got, _ := ts.Svc.Get(p.ID)
+	got, _ := ts.Svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 0 {
 		t.Fatalf("expected 0 votes after first click, got %d", len(got.Votes))
 	}
@@ -161,7 +162,7 @@ func TestTwoClickConfirmIncomplete(t *testing.T) {
 		t.Fatalf("second click (submit): %v", err)
 	}
 
-	got, _ = ts.Svc.Get(p.ID)
+	got, _ = ts.Svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote after second click, got %d", len(got.Votes))
 	}
@@ -202,7 +203,7 @@ func TestTwoClickConfirmResetOnVoteButton(t *testing.T) {
 	}
 
 	// Verify no vote was submitted.
-	got, _ := ts.Svc.Get(p.ID)
+	got, _ := ts.Svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 0 {
 		t.Errorf("expected 0 votes, got %d", len(got.Votes))
 	}
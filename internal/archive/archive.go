@@ -0,0 +1,205 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"meetkat/internal/poll"
+)
+
+// Format identifies a meetkat archive, the portable JSON format for moving
+// polls between instances. Version is bumped on incompatible changes;
+// readers reject archives newer than they understand. Version 2 made
+// admin_id optional in favour of admin_hash.
+const (
+	Format  = "meetkat-archive"
+	Version = 2
+)
+
+// Archive is the top-level document.
+type Archive struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Polls      []Poll    `json:"polls"`
+}
+
+// Poll is the archived form of a poll. AdminHash is the keyed hash of the
+// admin token and only opens the admin link on instances sharing the secret
+// key. AdminID, the token itself, is included when it is known (exports from
+// the admin page), so archives must be handled as confidentially as the
+// database itself.
+type Poll struct {
+	ID          string    `json:"id"`
+	AdminID     string    `json:"admin_id,omitempty"`
+	AdminHash   string    `json:"admin_hash,omitempty"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	AnswerMode  string    `json:"answer_mode"`
+	Options     []string  `json:"options"`
+	CreatedAt   time.Time `json:"created_at"`
+	Votes       []Vote    `json:"votes"`
+	// PasswordHash keeps a password-protected poll protected after import.
+	PasswordHash      string     `json:"password_hash,omitempty"`
+	ResultsVisibility string     `json:"results_visibility,omitempty"`
+	NameVisibility    string     `json:"name_visibility,omitempty"`
+	ClosedAt          *time.Time `json:"closed_at,omitempty"`
+}
+
+// Vote is the archived form of a vote.
+type Vote struct {
+	Name      string            `json:"name"`
+	Responses map[string]string `json:"responses"`
+	VotedAt   time.Time         `json:"voted_at"`
+	EditedAt  *time.Time        `json:"edited_at,omitempty"`
+}
+
+// New builds an archive containing polls.
+func New(polls []*poll.Poll, exportedAt time.Time) *Archive {
+	a := &Archive{
+		Format:     Format,
+		Version:    Version,
+		ExportedAt: exportedAt.UTC(),
+		Polls:      make([]Poll, 0, len(polls)),
+	}
+	for _, p := range polls {
+		a.Polls = append(a.Polls, FromPoll(p))
+	}
+	return a
+}
+
+// FromPoll converts a domain poll to its archived form.
+func FromPoll(p *poll.Poll) Poll {
+	ap := Poll{
+		ID:          p.ID,
+		AdminID:     p.AdminID,
+		AdminHash:   p.AdminHash,
+		Title:       p.Title,
+		Description: p.Description,
+		AnswerMode:  p.AnswerMode,
+		Options:     p.Options,
+		CreatedAt:   p.CreatedAt.UTC(),
+		Votes:       make([]Vote, 0, len(p.Votes)),
+
+		PasswordHash:      p.PasswordHash,
+		ResultsVisibility: p.ResultsVisibility,
+		NameVisibility:    p.NameVisibility,
+	}
+	if p.Closed() {
+		closed := p.ClosedAt.UTC()
+		ap.ClosedAt = &closed
+	}
+	for _, v := range p.Votes {
+		av := Vote{Name: v.Name, Responses: v.Responses, VotedAt: v.VotedAt.UTC()}
+		if !v.EditedAt.IsZero() {
+			edited := v.EditedAt.UTC()
+			av.EditedAt = &edited
+		}
+		ap.Votes = append(ap.Votes, av)
+	}
+	return ap
+}
+
+// ToPoll converts an archived poll back to a domain poll.
+func (ap Poll) ToPoll() *poll.Poll {
+	p := &poll.Poll{
+		ID:          ap.ID,
+		AdminID:     ap.AdminID,
+		AdminHash:   ap.AdminHash,
+		Title:       ap.Title,
+		Description: ap.Description,
+		AnswerMode:  ap.AnswerMode,
+		Options:     ap.Options,
+		CreatedAt:   ap.CreatedAt,
+
+		PasswordHash:      ap.PasswordHash,
+		ResultsVisibility: ap.ResultsVisibility,
+		NameVisibility:    ap.NameVisibility,
+	}
+	if ap.ClosedAt != nil {
+		p.ClosedAt = *ap.ClosedAt
+	}
+	for _, av := range ap.Votes {
+		v := poll.Vote{Name: av.Name, Responses: av.Responses, VotedAt: av.VotedAt}
+		if av.EditedAt != nil {
+			v.EditedAt = *av.EditedAt
+		}
+		p.Votes = append(p.Votes, v)
+	}
+	return p
+}
+
+// Write encodes a as indented JSON.
+func Write(w io.Writer, a *Archive) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// Read decodes an archive and checks that this version can import it.
+func Read(r io.Reader) (*Archive, error) {
+	var a Archive
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return nil, fmt.Errorf("decode archive: %w", err)
+	}
+	if a.Format != Format {
+		return nil, errors.New("not a meetkat archive")
+	}
+	if a.Version < 1 || a.Version > Version {
+		return nil, fmt.Errorf("unsupported archive version %d (this build reads up to %d)", a.Version, Version)
+	}
+	return &a, nil
+}
+
+// Export builds an archive of the polls with the given public IDs, or of
+// every poll when ids is empty.
+func Export(ctx context.Context, svc *poll.Service, ids []string, now time.Time) (*Archive, error) {
+	if len(ids) == 0 {
+		var err error
+		if ids, err = svc.ListIDs(ctx); err != nil {
+			return nil, fmt.Errorf("list polls: %w", err)
+		}
+	}
+	polls := make([]*poll.Poll, 0, len(ids))
+	for _, id := range ids {
+		p, err := svc.Get(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("load poll %s: %w", id, err)
+		}
+		if p == nil {
+			return nil, fmt.Errorf("poll %s not found", id)
+		}
+		polls = append(polls, p)
+	}
+	return New(polls, now), nil
+}
+
+// Result reports the outcome of importing one archived poll.
+type Result struct {
+	OriginalID string
+	Poll       *poll.Poll // nil if the poll was skipped
+}
+
+// Renamed reports whether the poll was stored under new IDs.
+func (r Result) Renamed() bool {
+	return r.Poll != nil && r.Poll.ID != r.OriginalID
+}
+
+// Import stores every poll in a, resolving ID collisions with onConflict
+// (one of the poll.Import* policies). It stops at the first error; polls
+// imported before it remain stored.
+func Import(ctx context.Context, svc *poll.Service, a *Archive, onConflict string) ([]Result, error) {
+	results := make([]Result, 0, len(a.Polls))
+	for _, ap := range a.Polls {
+		p, err := svc.Import(ctx, ap.ToPoll(), onConflict)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, Result{OriginalID: ap.ID, Poll: p})
+	}
+	return results, nil
+}
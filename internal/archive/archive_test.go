@@ -0,0 +1,166 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"meetkat/internal/poll"
+)
+
+func seedService(t *testing.T) (*poll.Service, *poll.Poll) {
+	t.Helper()
+	svc := poll.NewService(poll.NewMemoryRepository())
+	p, err := svc.Create(context.Background(), "Offsite", "Pick a week", "ymn", []string{"2026-03-02", "2026-03-09"}, poll.Settings{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"2026-03-02": "yes", "2026-03-09": "maybe"}); err != nil {
+		t.Fatalf("vote: %v", err)
+	}
+	if err := svc.UpdateVote(context.Background(), p.ID, "Alice", "Alice", map[string]string{"2026-03-02": "yes", "2026-03-09": "no"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := svc.SetClosed(context.Background(), p.ID, true); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return svc, p
+}
+
+func TestRoundTrip(t *testing.T) {
+	src, p := seedService(t)
+
+	a, err := Export(context.Background(), src, nil, time.Now())
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, a); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	dst := poll.NewService(poll.NewMemoryRepository())
+	results, err := Import(context.Background(), dst, read, poll.ImportFail)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(results) != 1 || results[0].Renamed() {
+		t.Fatalf("results: got %+v, want one poll under its original ID", results)
+	}
+
+	got, _ := dst.GetByAdminID(context.Background(), p.AdminID)
+	if got == nil {
+		t.Fatal("expected poll to be reachable by its original admin ID")
+	}
+	if got.ID != p.ID || got.Title != p.Title || got.Description != p.Description || got.AnswerMode != "ymn" {
+		t.Errorf("poll: got %+v", got)
+	}
+	if !got.Closed() {
+		t.Error("expected the poll to stay closed")
+	}
+	if !got.CreatedAt.Equal(p.CreatedAt) {
+		t.Errorf("created_at: got %v, want %v", got.CreatedAt, p.CreatedAt)
+	}
+	if len(got.Votes) != 1 {
+		t.Fatalf("votes: got %d, want 1", len(got.Votes))
+	}
+	v := got.Votes[0]
+	if v.Name != "Alice" || v.Responses["2026-03-09"] != "no" {
+		t.Errorf("vote: got %+v", v)
+	}
+	if v.VotedAt.IsZero() || v.EditedAt.IsZero() {
+		t.Errorf("expected vote timestamps to survive, got voted %v edited %v", v.VotedAt, v.EditedAt)
+	}
+}
+
+func TestImportRehashesAdminID(t *testing.T) {
+	src, p := seedService(t)
+	full, err := src.GetByAdminID(context.Background(), p.AdminID)
+	if err != nil {
+		t.Fatalf("get by admin id: %v", err)
+	}
+	a := New([]*poll.Poll{full}, time.Now())
+
+	dst := poll.NewService(poll.NewMemoryRepository())
+	dst.SetAdminKey([]byte("another instance"))
+	if _, err := Import(context.Background(), dst, a, poll.ImportFail); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if got, _ := dst.GetByAdminID(context.Background(), p.AdminID); got == nil {
+		t.Error("expected the admin ID to work under the importing instance's key")
+	}
+}
+
+func TestImportConflicts(t *testing.T) {
+	svc, p := seedService(t)
+	a, err := Export(context.Background(), svc, []string{p.ID}, time.Now())
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	results, err := Import(context.Background(), svc, a, poll.ImportSkip)
+	if err != nil {
+		t.Fatalf("skip: %v", err)
+	}
+	if len(results) != 1 || results[0].Poll != nil {
+		t.Errorf("skip: got %+v, want skipped poll", results)
+	}
+
+	if _, err := Import(context.Background(), svc, a, poll.ImportFail); !errors.Is(err, poll.ErrIDConflict) {
+		t.Errorf("fail: got %v, want ErrIDConflict", err)
+	}
+
+	results, err = Import(context.Background(), svc, a, poll.ImportRename)
+	if err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if len(results) != 1 || !results[0].Renamed() {
+		t.Fatalf("rename: got %+v, want renamed poll", results)
+	}
+	renamed := results[0].Poll
+	if renamed.AdminID == p.AdminID {
+		t.Error("expected renamed poll to get a new admin ID")
+	}
+	got, _ := svc.Get(context.Background(), renamed.ID)
+	if got == nil || len(got.Votes) != 1 {
+		t.Fatalf("expected renamed copy with its vote, got %+v", got)
+	}
+	if orig, _ := svc.Get(context.Background(), p.ID); orig == nil {
+		t.Error("expected original poll to be untouched")
+	}
+}
+
+func TestImportValidates(t *testing.T) {
+	svc := poll.NewService(poll.NewMemoryRepository())
+	a := &Archive{Format: Format, Version: Version, Polls: []Poll{{
+		ID: "imp12345", AdminID: "adm12345", Title: "", AnswerMode: "yn", Options: []string{"A"},
+	}}}
+	if _, err := Import(context.Background(), svc, a, poll.ImportSkip); err == nil {
+		t.Fatal("expected error for poll without title")
+	}
+}
+
+func TestReadRejectsUnknownArchives(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"not json", "nope"},
+		{"wrong format", `{"format":"something-else","version":1}`},
+		{"future version", `{"format":"meetkat-archive","version":99}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(strings.NewReader(tt.json)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
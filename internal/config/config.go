@@ -1,24 +1,510 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Config holds application-wide settings.
 type Config struct {
 	DBPath string
-	Port   string
+	// SecretKeyFile holds the instance secret used to hash admin links and
+	// sign tokens. It is created on first start; empty means "meetkat.key"
+	// next to DBPath.
+	SecretKeyFile string
+	// Port is the TCP listen port; empty disables the TCP listener.
+	Port string
+	// UnixSocket additionally listens on a Unix domain socket at this path,
+	// created with UnixSocketMode (octal, e.g. "0660"). Sockets passed by
+	// systemd socket activation replace both listeners.
+	UnixSocket     string
+	UnixSocketMode string
+
+	// TLSCert and TLSKey enable HTTPS on Port with this certificate and key.
+	// The files are reloaded on SIGHUP and, if TLSWatchInterval is positive,
+	// when they change.
+	TLSCert          string
+	TLSKey           string
+	TLSWatchInterval time.Duration
+	// TLSRedirectAddr starts a second listener (e.g. ":80") that redirects
+	// plain HTTP requests to HTTPS.
+	TLSRedirectAddr string
+
+	// GinMode is the Gin framework mode: "release", "debug" or "test".
+	GinMode string
+	// BaseURL is the canonical public URL of the instance including any
+	// BasePath (e.g. "https://meet.example.com"), used for every absolute
+	// link. When empty, links are built from the request's Host header.
+	BaseURL string
+	// BasePath is the URL path prefix the app is served under (e.g.
+	// "/meetkat"), without a trailing slash; empty serves at the root.
+	BasePath string
+	// TrustedProxies lists the IPs and CIDRs of reverse proxies whose
+	// X-Forwarded-For and X-Forwarded-Proto headers are believed when
+	// determining the client IP and scheme. Empty trusts no proxy.
+	TrustedProxies []string
+	// TrustedPlatform names a header set by the hosting platform that carries
+	// the client IP: "cloudflare", "google", "flyio" or a header name. It must
+	// only be set when every request passes through that platform.
+	TrustedPlatform string
+
+	// CookieSecure controls the Secure attribute of cookies: "auto" sets it
+	// on HTTPS requests, "always" and "never" force it.
+	CookieSecure string
+	// CookieSameSite is the SameSite attribute: "lax", "strict" or "none".
+	CookieSameSite string
+	// CookieDomain is the Domain attribute; empty means host-only cookies.
+	CookieDomain string
+
+	// Limits on user input.
+	MaxTitleLen       int
+	MaxDescriptionLen int
+	MaxNameLen        int
+	MaxOptions        int
+
+	// Per-client rate limits in requests per minute, with burst capacity.
+	RateLimitCreate      int
+	RateLimitCreateBurst int
+	RateLimitVote        int
+	RateLimitVoteBurst   int
+	// RateLimitUnlock throttles password attempts on protected polls.
+	RateLimitUnlock      int
+	RateLimitUnlockBurst int
+	// RateLimitRoutes gives single routes their own limit instead of the one
+	// above that covers them, as "METHOD /path=perMinute/burst" entries with
+	// the path pattern relative to BasePath, e.g. "POST /poll/:id/vote=10/5".
+	// The /healthz and /readyz probes cannot be limited.
+	RateLimitRoutes []string
+	// RateLimitAllow lists IPs and CIDRs, such as internal networks or
+	// monitoring, that are never rate limited.
+	RateLimitAllow []string
+
+	// SpamProtection guards poll creation and votes with a honeypot field
+	// and a signed form token that must be at least SpamMinFillTime old.
+	// SpamPoWBits > 0 additionally makes browsers solve a proof of work of
+	// that many bits.
+	SpamProtection  bool
+	SpamMinFillTime time.Duration
+	SpamPoWBits     int
+
+	// LogFormat selects the log output: "text" or "json".
+	LogFormat string
+	// LogLevel is the minimum level logged: "debug", "info", "warn" or "error".
+	LogLevel string
+
+	// AutoMigrate applies pending schema migrations at startup. When false,
+	// the server refuses to start until they are applied with
+	// "meetkat migrate up".
+	AutoMigrate bool
+
+	// ShutdownDelay is how long the server keeps serving after SIGTERM with
+	// a failing readiness probe before it stops accepting connections.
+	ShutdownDelay time.Duration
+
+	// TracingEnabled exports OpenTelemetry spans over OTLP/HTTP to
+	// TracingEndpoint, sampling TracingSampleRatio of new traces.
+	TracingEnabled     bool
+	TracingEndpoint    string
+	TracingSampleRatio float64
+
+	// MetricsEnabled exposes Prometheus metrics at /metrics. They are served
+	// on MetricsAddr (e.g. "127.0.0.1:9090") if set, otherwise on the main
+	// listener under BasePath, where anyone can read them.
+	MetricsEnabled bool
+	MetricsAddr    string
+
+	// OperatorPassword enables the operator console at /operator, protected
+	// by HTTP basic auth with user "operator" and this password.
+	// OperatorAddr (e.g. "127.0.0.1:9091") serves the console on a listener
+	// of its own instead; on a loopback address it may go without password.
+	OperatorPassword string
+	OperatorAddr     string
+
+	// CacheSize is the maximum number of polls kept in the read-through
+	// cache; 0 disables the cache.
+	CacheSize int
+	// CacheTTL bounds how long a cached poll is served, and so how long
+	// changes made to it by another process stay unseen; 0 means no expiry.
+	CacheTTL time.Duration
+
+	// BackupDir enables scheduled snapshots of the database into this
+	// directory when non-empty.
+	BackupDir      string
+	BackupInterval time.Duration
+	// BackupKeep is the number of snapshots retained in BackupDir.
+	BackupKeep int
+
+	// RetentionDays enables the janitor that purges polls this many days
+	// after RetentionBasis; 0 keeps polls forever.
+	RetentionDays      int
+	RetentionBasis     string // "created", "last_vote" or "closed"
+	RetentionInterval  time.Duration
+	RetentionBatchSize int
+	RetentionDryRun    bool
+
+	// sources records where each non-default setting came from, by key.
+	sources map[string]string
+}
+
+// Default returns the configuration used when nothing is set.
+func Default() Config {
+	return Config{
+		DBPath:   "data/meetkat.db",
+		Port:     "8080",
+		CacheTTL: 5 * time.Minute,
+
+		UnixSocketMode: "0660",
+
+		TLSWatchInterval: time.Minute,
+
+		GinMode:        "release",
+		CookieSecure:   "auto",
+		CookieSameSite: "lax",
+
+		MaxTitleLen:       200,
+		MaxDescriptionLen: 2000,
+		MaxNameLen:        100,
+		MaxOptions:        60,
+
+		RateLimitCreate:      10,
+		RateLimitCreateBurst: 10,
+		RateLimitVote:        30,
+		RateLimitVoteBurst:   30,
+		RateLimitUnlock:      5,
+		RateLimitUnlockBurst: 5,
+
+		SpamProtection:  true,
+		SpamMinFillTime: 2 * time.Second,
+
+		LogFormat: "text",
+		LogLevel:  "info",
+
+		AutoMigrate: true,
+
+		TracingEndpoint:    "http://localhost:4318",
+		TracingSampleRatio: 1,
+
+		BackupInterval: 24 * time.Hour,
+		BackupKeep:     7,
+
+		RetentionBasis:     "created",
+		RetentionInterval:  time.Hour,
+		RetentionBatchSize: 100,
+	}
 }
 
-// Load reads configuration from environment variables with sensible defaults.
-func Load() Config {
-	cfg := Config{
-		DBPath: "data/meetkat.db",
-		Port:   "8080",
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, a TOML config file (-config or MEETKAT_CONFIG), MEETKAT_*
+// environment variables and command-line flags. Flag parsing stops at the
+// first non-flag argument; the remaining arguments (a subcommand and its
+// arguments) are returned. The result is validated.
+func Load(args []string) (Config, []string, error) {
+	cfg := Default()
+	cfg.sources = make(map[string]string)
+	settings := cfg.settings()
+
+	fs := flag.NewFlagSet("meetkat", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configPath := fs.String("config", os.Getenv("MEETKAT_CONFIG"), "path to a TOML config file")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		_, isBool := s.ptr.(*bool)
+		fs.Var(&rawFlag{key: s.key, values: flagValues, isBool: isBool}, flagName(s.key), s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath, settings); err != nil {
+			return cfg, nil, err
+		}
+	}
+	for _, s := range settings {
+		name := envName(s.key)
+		v := os.Getenv(name)
+		if legacy := legacyEnv[s.key]; v == "" && legacy != "" {
+			name, v = legacy, os.Getenv(legacy)
+		}
+		if v == "" {
+			continue
+		}
+		if err := s.parse(v); err != nil {
+			return cfg, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		cfg.sources[s.key] = "env " + name
 	}
-	if v := os.Getenv("MEETKAT_DB_PATH"); v != "" {
-		cfg.DBPath = v
+	for _, s := range settings {
+		v, ok := flagValues[s.key]
+		if !ok {
+			continue
+		}
+		if err := s.parse(v); err != nil {
+			return cfg, nil, fmt.Errorf("-%s: %w", flagName(s.key), err)
+		}
+		cfg.sources[s.key] = "flag -" + flagName(s.key)
 	}
-	if v := os.Getenv("MEETKAT_PORT"); v != "" {
-		cfg.Port = v
+
+	cfg.BasePath = strings.TrimRight(cfg.BasePath, "/")
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if err := cfg.Validate(); err != nil {
+		return cfg, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// Usage writes the global flags to w.
+func Usage(w io.Writer) {
+	cfg := Default()
+	_, _ = fmt.Fprintln(w, "Flags (each can also be set as MEETKAT_<NAME> or in the -config file):")
+	_, _ = fmt.Fprintln(w, "  -config path\n        path to a TOML config file")
+	for _, s := range cfg.settings() {
+		_, _ = fmt.Fprintf(w, "  -%s\n        %s\n", flagName(s.key), s.usage)
+	}
+}
+
+// SecretKeyPath returns SecretKeyFile, or its default location next to the
+// database.
+func (c Config) SecretKeyPath() string {
+	if c.SecretKeyFile != "" {
+		return c.SecretKeyFile
+	}
+	return filepath.Join(filepath.Dir(c.DBPath), "meetkat.key")
+}
+
+// SocketMode returns UnixSocketMode as a file mode.
+func (c Config) SocketMode() os.FileMode {
+	mode, _ := strconv.ParseUint(c.UnixSocketMode, 8, 32)
+	return os.FileMode(mode) & os.ModePerm
+}
+
+// PlatformHeader returns the header named by TrustedPlatform, resolving the
+// platform aliases.
+func (c Config) PlatformHeader() string {
+	switch strings.ToLower(c.TrustedPlatform) {
+	case "cloudflare":
+		return "CF-Connecting-IP"
+	case "google":
+		return "X-Appengine-Remote-Addr"
+	case "flyio":
+		return "Fly-Client-IP"
+	}
+	return c.TrustedPlatform
+}
+
+// RouteRateLimit is a per-route rate limit from RateLimitRoutes.
+type RouteRateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// RouteRateLimits returns RateLimitRoutes keyed by "METHOD /path". Entries
+// rejected by Validate are left out.
+func (c Config) RouteRateLimits() map[string]RouteRateLimit {
+	limits := make(map[string]RouteRateLimit, len(c.RateLimitRoutes))
+	for _, entry := range c.RateLimitRoutes {
+		if route, limit, ok := parseRouteRateLimit(entry); ok {
+			limits[route] = limit
+		}
+	}
+	return limits
+}
+
+func parseRouteRateLimit(entry string) (string, RouteRateLimit, bool) {
+	route, spec, ok := strings.Cut(entry, "=")
+	method, path, _ := strings.Cut(strings.TrimSpace(route), " ")
+	perMinute, burst, _ := strings.Cut(spec, "/")
+	limit := RouteRateLimit{}
+	var errRate, errBurst error
+	limit.PerMinute, errRate = strconv.Atoi(strings.TrimSpace(perMinute))
+	limit.Burst, errBurst = strconv.Atoi(strings.TrimSpace(burst))
+	path = strings.TrimSpace(path)
+	ok = ok && method != "" && isHeaderName(method) && method == strings.ToUpper(method) && strings.HasPrefix(path, "/") &&
+		errRate == nil && errBurst == nil && limit.PerMinute > 0 && limit.Burst > 0
+	return method + " " + path, limit, ok
+}
+
+func isHeaderName(s string) bool {
+	for _, r := range s {
+		if !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// isLoopback reports whether host names only the local machine.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+// OperatorEnabled reports whether the operator console is served.
+func (c Config) OperatorEnabled() bool {
+	return c.OperatorPassword != "" || c.OperatorAddr != ""
+}
+
+// Validate checks the configuration for values the server cannot use and
+// reports all problems at once.
+func (c Config) Validate() error {
+	var problems []string
+	check := func(key string, ok bool, format string, args ...any) {
+		if ok {
+			return
+		}
+		msg := key + ": " + fmt.Sprintf(format, args...)
+		if src := c.sources[key]; src != "" {
+			msg += " (set by " + src + ")"
+		}
+		problems = append(problems, msg)
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		check(key, false, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+
+	check("db_path", c.DBPath != "", "must not be empty")
+	if c.Port != "" {
+		port, err := strconv.Atoi(c.Port)
+		check("port", err == nil && port > 0 && port < 65536, "must be a port number between 1 and 65535, got %q", c.Port)
+	}
+	_, err := strconv.ParseUint(c.UnixSocketMode, 8, 32)
+	check("unix_socket_mode", err == nil, "must be an octal file mode such as 0660, got %q", c.UnixSocketMode)
+	check("tls_cert", (c.TLSCert == "") == (c.TLSKey == ""), "tls_cert and tls_key must be set together")
+	if c.TLSRedirectAddr != "" {
+		check("tls_redirect_addr", c.TLSCert != "", "requires tls_cert and tls_key")
+		check("tls_redirect_addr", c.Port != "" || c.BaseURL != "", "requires port or base_url as redirect target")
+		_, _, err := net.SplitHostPort(c.TLSRedirectAddr)
+		check("tls_redirect_addr", err == nil, "must be host:port, got %q", c.TLSRedirectAddr)
+	}
+	oneOf("gin_mode", c.GinMode, "release", "debug", "test")
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		check("base_url", err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.RawQuery == "" && u.Fragment == "",
+			"must be an absolute http(s) URL without query or fragment, got %q", c.BaseURL)
+		if err == nil {
+			check("base_url", strings.TrimRight(u.Path, "/") == c.BasePath,
+				"path %q must match base_path %q", u.Path, c.BasePath)
+		}
+	}
+	if c.BasePath != "" {
+		u, err := url.Parse(c.BasePath)
+		check("base_path", err == nil && strings.HasPrefix(c.BasePath, "/") && u.EscapedPath() == c.BasePath && u.RawQuery == "" && !strings.HasSuffix(c.BasePath, "/"),
+			`must be a URL path such as "/meetkat" without query or trailing slash, got %q`, c.BasePath)
+	}
+	for _, p := range c.TrustedProxies {
+		_, errPrefix := netip.ParsePrefix(p)
+		_, errAddr := netip.ParseAddr(p)
+		check("trusted_proxies", errPrefix == nil || errAddr == nil, "%q is not an IP address or CIDR", p)
+	}
+	for _, entry := range c.RateLimitRoutes {
+		_, _, ok := parseRouteRateLimit(entry)
+		check("rate_limit_routes", ok, `%q is not of the form "METHOD /path=perMinute/burst" with positive numbers`, entry)
+	}
+	for _, p := range c.RateLimitAllow {
+		_, errPrefix := netip.ParsePrefix(p)
+		_, errAddr := netip.ParseAddr(p)
+		check("rate_limit_allow", errPrefix == nil || errAddr == nil, "%q is not an IP address or CIDR", p)
+	}
+	if h := c.PlatformHeader(); h != "" {
+		check("trusted_platform", isHeaderName(h), "must be cloudflare, google, flyio or a header name, got %q", c.TrustedPlatform)
+	}
+
+	oneOf("cookie_secure", c.CookieSecure, "auto", "always", "never")
+	oneOf("cookie_same_site", c.CookieSameSite, "lax", "strict", "none")
+	if c.CookieSameSite == "none" {
+		check("cookie_same_site", c.CookieSecure == "always", `"none" requires cookie_secure = "always"`)
+	}
+
+	for key, v := range map[string]int{
+		"max_title_len":           c.MaxTitleLen,
+		"max_description_len":     c.MaxDescriptionLen,
+		"max_name_len":            c.MaxNameLen,
+		"max_options":             c.MaxOptions,
+		"rate_limit_create":       c.RateLimitCreate,
+		"rate_limit_create_burst": c.RateLimitCreateBurst,
+		"rate_limit_vote":         c.RateLimitVote,
+		"rate_limit_vote_burst":   c.RateLimitVoteBurst,
+		"rate_limit_unlock":       c.RateLimitUnlock,
+		"rate_limit_unlock_burst": c.RateLimitUnlockBurst,
+		"retention_batch_size":    c.RetentionBatchSize,
+	} {
+		check(key, v > 0, "must be positive, got %d", v)
+	}
+	for key, v := range map[string]int{
+		"cache_size":     c.CacheSize,
+		"backup_keep":    c.BackupKeep,
+		"retention_days": c.RetentionDays,
+	} {
+		check(key, v >= 0, "must not be negative, got %d", v)
+	}
+	for key, v := range map[string]time.Duration{
+		"shutdown_delay":     c.ShutdownDelay,
+		"tls_watch_interval": c.TLSWatchInterval,
+		"cache_ttl":          c.CacheTTL,
+		"spam_min_fill_time": c.SpamMinFillTime,
+		"backup_interval":    c.BackupInterval,
+		"retention_interval": c.RetentionInterval,
+	} {
+		check(key, v >= 0, "must not be negative, got %s", v)
+	}
+	if c.BackupDir != "" {
+		check("backup_interval", c.BackupInterval > 0, "must be positive when backup_dir is set")
+	}
+	if c.RetentionDays > 0 {
+		check("retention_interval", c.RetentionInterval > 0, "must be positive when retention_days is set")
+	}
+
+	check("spam_pow_bits", c.SpamPoWBits >= 0 && c.SpamPoWBits <= 24,
+		"must be between 0 and 24, got %d", c.SpamPoWBits)
+
+	oneOf("log_format", c.LogFormat, "text", "json")
+	oneOf("log_level", c.LogLevel, "debug", "info", "warn", "error")
+	oneOf("retention_basis", c.RetentionBasis, "created", "last_vote", "closed")
+	check("tracing_sample_ratio", c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1,
+		"must be between 0 and 1, got %v", c.TracingSampleRatio)
+	if c.TracingEnabled {
+		u, err := url.Parse(c.TracingEndpoint)
+		check("tracing_endpoint", err == nil && u.Scheme != "" && u.Host != "",
+			"must be a URL such as http://localhost:4318, got %q", c.TracingEndpoint)
+	}
+	if c.MetricsAddr != "" {
+		_, _, err := net.SplitHostPort(c.MetricsAddr)
+		check("metrics_addr", err == nil, "must be host:port, got %q", c.MetricsAddr)
+	}
+	if c.OperatorPassword != "" {
+		check("operator_password", len(c.OperatorPassword) >= 12, "must be at least 12 characters long")
+	}
+	if c.OperatorAddr != "" {
+		host, _, err := net.SplitHostPort(c.OperatorAddr)
+		check("operator_addr", err == nil, "must be host:port, got %q", c.OperatorAddr)
+		if err == nil && c.OperatorPassword == "" {
+			check("operator_addr", isLoopback(host), "must be a loopback address such as 127.0.0.1:9091 unless operator_password is set")
+		}
+	}
+
+	if len(problems) > 0 {
+		// Map iteration above is unordered; sort for stable output.
+		sort.Strings(problems)
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
 	}
-	return cfg
+	return nil
 }
@@ -0,0 +1,267 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "meetkat.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, args, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(args) != 0 {
+		t.Errorf("args = %v, want none", args)
+	}
+	want := Default()
+	cfg.sources, want.sources = nil, nil
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Load() = %+v, want defaults %+v", cfg, want)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+port = "9000"
+log_level = "debug"
+cache_size = 50
+cache_ttl = "1m"
+trusted_proxies = ["10.0.0.0/8"]
+`)
+	t.Setenv("MEETKAT_CONFIG", path)
+	t.Setenv("MEETKAT_LOG_LEVEL", "warn")
+	t.Setenv("MEETKAT_CACHE_SIZE", "60")
+
+	cfg, args, err := Load([]string{"-cache-size=70", "-tracing", "migrate", "status"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Port != "9000" {
+		t.Errorf("Port = %q, want file value 9000", cfg.Port)
+	}
+	if cfg.CacheTTL != time.Minute {
+		t.Errorf("CacheTTL = %s, want file value 1m", cfg.CacheTTL)
+	}
+	if !reflect.DeepEqual(cfg.TrustedProxies, []string{"10.0.0.0/8"}) {
+		t.Errorf("TrustedProxies = %v, want file value", cfg.TrustedProxies)
+	}
+	if cfg.LogLevel != "warn" {
+		t.Errorf("LogLevel = %q, want env to override file", cfg.LogLevel)
+	}
+	if cfg.CacheSize != 70 {
+		t.Errorf("CacheSize = %d, want flag to override env and file", cfg.CacheSize)
+	}
+	if !cfg.TracingEnabled {
+		t.Error("bare -tracing flag did not enable tracing")
+	}
+	if !reflect.DeepEqual(args, []string{"migrate", "status"}) {
+		t.Errorf("args = %v, want [migrate status]", args)
+	}
+}
+
+func TestLoadLegacyGinMode(t *testing.T) {
+	t.Setenv("GIN_MODE", "debug")
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.GinMode != "debug" {
+		t.Errorf("GinMode = %q, want debug from GIN_MODE", cfg.GinMode)
+	}
+
+	t.Setenv("MEETKAT_GIN_MODE", "test")
+	cfg, _, err = Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.GinMode != "test" {
+		t.Errorf("GinMode = %q, want MEETKAT_GIN_MODE to win over GIN_MODE", cfg.GinMode)
+	}
+}
+
+func TestLoadRejectsUnknownFileKey(t *testing.T) {
+	path := writeConfigFile(t, `prot = "9000"`)
+	_, _, err := Load([]string{"-config", path})
+	if err == nil || !strings.Contains(err.Error(), `unknown setting "prot"`) {
+		t.Fatalf("err = %v, want unknown setting error", err)
+	}
+}
+
+func TestLoadRejectsMistypedValues(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+		want string
+	}{
+		{name: "env int", env: map[string]string{"MEETKAT_CACHE_SIZE": "lots"}, want: "MEETKAT_CACHE_SIZE: invalid integer"},
+		{name: "flag duration", args: []string{"-cache-ttl=5"}, want: "-cache-ttl: invalid duration"},
+		{name: "file type", file: `cache_size = "10"`, want: "cache_size: expected an integer"},
+		{name: "unknown flag", args: []string{"-no-such-flag"}, want: "no-such-flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfigFile(t, tt.file)}, args...)
+			}
+			_, _, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllProblemsWithSource(t *testing.T) {
+	t.Setenv("MEETKAT_PORT", "http")
+	_, _, err := Load([]string{"-log-format=xml", "-cookie-same-site=none", "-trusted-proxies=10.0.0.0/8,proxy", "-spam-pow-bits=40", "-rate-limit-routes=POST /new=5/5,/poll/:id=1/1"})
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		`port: must be a port number between 1 and 65535, got "http" (set by env MEETKAT_PORT)`,
+		`log_format: must be one of text, json, got "xml" (set by flag -log-format)`,
+		`cookie_same_site: "none" requires cookie_secure = "always"`,
+		`trusted_proxies: "proxy" is not an IP address or CIDR`,
+		`spam_pow_bits: must be between 0 and 24, got 40 (set by flag -spam-pow-bits)`,
+		`rate_limit_routes: "/poll/:id=1/1" is not of the form "METHOD /path=perMinute/burst" with positive numbers`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestLoadBasePath(t *testing.T) {
+	cfg, _, err := Load([]string{"-base-path=/tools/meetkat/"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.BasePath != "/tools/meetkat" {
+		t.Errorf("BasePath = %q, want trailing slash trimmed", cfg.BasePath)
+	}
+
+	for _, bad := range []string{"meetkat", "/meet kat", "/meetkat?x=1"} {
+		if _, _, err := Load([]string{"-base-path=" + bad}); err == nil || !strings.Contains(err.Error(), "base_path") {
+			t.Errorf("base path %q: err = %v, want base_path error", bad, err)
+		}
+	}
+}
+
+func TestValidateBaseURLMatchesBasePath(t *testing.T) {
+	if _, _, err := Load([]string{"-base-url=https://tools.example.com/meetkat/", "-base-path=/meetkat"}); err != nil {
+		t.Errorf("matching base URL and path: %v", err)
+	}
+	_, _, err := Load([]string{"-base-url=https://tools.example.com/meetkat"})
+	if err == nil || !strings.Contains(err.Error(), `base_url: path "/meetkat" must match base_path ""`) {
+		t.Errorf("err = %v, want base_url/base_path mismatch", err)
+	}
+}
+
+func TestRouteRateLimits(t *testing.T) {
+	cfg := Default()
+	cfg.RateLimitRoutes = []string{"POST /poll/:id/vote = 10/5", "GET /poll/:id=0/1"}
+	got := cfg.RouteRateLimits()
+	want := map[string]RouteRateLimit{"POST /poll/:id/vote": {PerMinute: 10, Burst: 5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RouteRateLimits = %v, want %v", got, want)
+	}
+}
+
+func TestValidateOperator(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-operator-addr=127.0.0.1:9091"}, ""},
+		{[]string{"-operator-addr=[::1]:9091"}, ""},
+		{[]string{"-operator-addr=localhost:9091"}, ""},
+		{[]string{"-operator-password=correct horse battery"}, ""},
+		{[]string{"-operator-addr=:9091", "-operator-password=correct horse battery"}, ""},
+		{[]string{"-operator-addr=:9091"}, "operator_addr: must be a loopback address"},
+		{[]string{"-operator-addr=192.0.2.1:9091"}, "operator_addr: must be a loopback address"},
+		{[]string{"-operator-addr=9091", "-operator-password=correct horse battery"}, "operator_addr: must be host:port"},
+		{[]string{"-operator-password=short"}, "operator_password: must be at least 12 characters long"},
+	}
+	for _, tt := range tests {
+		_, _, err := Load(tt.args)
+		if tt.want == "" && err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%v: err = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestWriteTOMLRedactsSecrets(t *testing.T) {
+	cfg, _, err := Load([]string{"-operator-password=correct horse battery"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := cfg.WriteTOML(&buf); err != nil {
+		t.Fatalf("WriteTOML: %v", err)
+	}
+	if strings.Contains(buf.String(), "correct horse") || !strings.Contains(buf.String(), "operator_password = '(redacted)'") {
+		t.Errorf("operator password not redacted:\n%s", buf.String())
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("defaults are invalid: %v", err)
+	}
+}
+
+func TestWriteTOMLRoundTrip(t *testing.T) {
+	t.Setenv("MEETKAT_BACKUP_DIR", "/var/backups/meetkat")
+	cfg, _, err := Load([]string{"-cache-ttl=90s", "-trusted-proxies=127.0.0.1,10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := cfg.WriteTOML(&buf); err != nil {
+		t.Fatalf("WriteTOML: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"[from env MEETKAT_BACKUP_DIR]\nbackup_dir = '/var/backups/meetkat'",
+		"[from flag -cache-ttl]\ncache_ttl = '1m30s'",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+
+	t.Setenv("MEETKAT_BACKUP_DIR", "")
+	reloaded, _, err := Load([]string{"-config", writeConfigFile(t, out)})
+	if err != nil {
+		t.Fatalf("Load printed config: %v", err)
+	}
+	cfg.sources, reloaded.sources = nil, nil
+	if !reflect.DeepEqual(reloaded, cfg) {
+		t.Errorf("reloaded config = %+v, want %+v", reloaded, cfg)
+	}
+}
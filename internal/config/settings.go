@@ -0,0 +1,285 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// setting binds a config key to a Config field. The key is used as is in the
+// config file, upper-cased with a MEETKAT_ prefix as environment variable,
+// and with dashes as flag name.
+type setting struct {
+	key   string
+	usage string
+	ptr   any // *string, *int, *bool, *float64, *time.Duration or *[]string
+}
+
+// legacyEnv maps keys to environment variables honoured for compatibility
+// when the MEETKAT_ variable is unset.
+var legacyEnv = map[string]string{
+	"gin_mode": "GIN_MODE",
+}
+
+// secretKeys are settings whose values WriteTOML does not reveal.
+var secretKeys = map[string]bool{
+	"operator_password": true,
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"db_path", "path to the SQLite database file", &c.DBPath},
+		{"secret_key_file", "instance secret key, created if missing (default: meetkat.key next to db_path)", &c.SecretKeyFile},
+		{"port", "HTTP listen port (empty disables the TCP listener)", &c.Port},
+		{"unix_socket", "also listen on a Unix domain socket at this path", &c.UnixSocket},
+		{"unix_socket_mode", "file mode of the Unix socket, in octal", &c.UnixSocketMode},
+		{"tls_cert", "TLS certificate file; serves HTTPS when set together with tls_key", &c.TLSCert},
+		{"tls_key", "TLS private key file", &c.TLSKey},
+		{"tls_watch_interval", "how often to check the TLS files for changes (0 reloads only on SIGHUP)", &c.TLSWatchInterval},
+		{"tls_redirect_addr", "address of an extra listener redirecting HTTP to HTTPS, e.g. :80", &c.TLSRedirectAddr},
+		{"gin_mode", "Gin mode: release, debug or test", &c.GinMode},
+		{"base_url", "public URL of this instance, e.g. https://meet.example.com", &c.BaseURL},
+		{"base_path", "URL path prefix to serve under, e.g. /meetkat (empty for the root)", &c.BasePath},
+		{"trusted_proxies", "comma-separated IPs/CIDRs of reverse proxies allowed to set X-Forwarded-For and X-Forwarded-Proto", &c.TrustedProxies},
+		{"trusted_platform", "client IP header of the hosting platform: cloudflare, google, flyio or a header name", &c.TrustedPlatform},
+		{"cookie_secure", "Secure cookie attribute: auto, always or never", &c.CookieSecure},
+		{"cookie_same_site", "SameSite cookie attribute: lax, strict or none", &c.CookieSameSite},
+		{"cookie_domain", "Domain cookie attribute (empty for host-only cookies)", &c.CookieDomain},
+		{"max_title_len", "maximum poll title length", &c.MaxTitleLen},
+		{"max_description_len", "maximum poll description length", &c.MaxDescriptionLen},
+		{"max_name_len", "maximum voter name length", &c.MaxNameLen},
+		{"max_options", "maximum number of options per poll", &c.MaxOptions},
+		{"rate_limit_create", "poll creations per minute per client", &c.RateLimitCreate},
+		{"rate_limit_create_burst", "burst size for poll creation", &c.RateLimitCreateBurst},
+		{"rate_limit_vote", "votes and admin actions per minute per client", &c.RateLimitVote},
+		{"rate_limit_vote_burst", "burst size for votes and admin actions", &c.RateLimitVoteBurst},
+		{"rate_limit_unlock", "password attempts on protected polls per minute per client", &c.RateLimitUnlock},
+		{"rate_limit_unlock_burst", "burst size for password attempts", &c.RateLimitUnlockBurst},
+		{"rate_limit_routes", `comma-separated per-route limits such as "POST /poll/:id/vote=10/5" (per minute/burst)`, &c.RateLimitRoutes},
+		{"rate_limit_allow", "comma-separated IPs/CIDRs that are never rate limited", &c.RateLimitAllow},
+		{"spam_protection", "reject bot submissions of polls and votes", &c.SpamProtection},
+		{"spam_min_fill_time", "minimum time between showing a form and submitting it", &c.SpamMinFillTime},
+		{"spam_pow_bits", "proof-of-work difficulty in bits for polls and votes (0 disables)", &c.SpamPoWBits},
+		{"log_format", "log format: text or json", &c.LogFormat},
+		{"log_level", "minimum log level: debug, info, warn or error", &c.LogLevel},
+		{"auto_migrate", "apply pending migrations at startup", &c.AutoMigrate},
+		{"shutdown_delay", "keep serving this long after SIGTERM while /readyz fails", &c.ShutdownDelay},
+		{"tracing", "export OpenTelemetry traces", &c.TracingEnabled},
+		{"tracing_endpoint", "OTLP/HTTP collector URL", &c.TracingEndpoint},
+		{"tracing_sample_ratio", "fraction of new traces sampled (0 to 1)", &c.TracingSampleRatio},
+		{"metrics", "expose Prometheus metrics at /metrics", &c.MetricsEnabled},
+		{"metrics_addr", "serve /metrics on this address instead of the main port", &c.MetricsAddr},
+		{"operator_password", "password of the operator console at /operator (user \"operator\")", &c.OperatorPassword},
+		{"operator_addr", "serve the operator console on this address instead of the main port", &c.OperatorAddr},
+		{"cache_size", "polls kept in the in-memory read cache (0 disables it)", &c.CacheSize},
+		{"cache_ttl", "maximum age of a cached poll, after which changes by other processes show up (0 keeps entries until evicted)", &c.CacheTTL},
+		{"backup_dir", "directory for scheduled database snapshots (empty disables them)", &c.BackupDir},
+		{"backup_interval", "time between scheduled snapshots", &c.BackupInterval},
+		{"backup_keep", "number of snapshots kept", &c.BackupKeep},
+		{"retention_days", "delete polls this many days after retention_basis (0 keeps them)", &c.RetentionDays},
+		{"retention_basis", "retention clock: created, last_vote or closed", &c.RetentionBasis},
+		{"retention_interval", "how often the retention janitor runs", &c.RetentionInterval},
+		{"retention_batch_size", "polls deleted per retention batch", &c.RetentionBatchSize},
+		{"retention_dry_run", "only log the polls retention would delete", &c.RetentionDryRun},
+	}
+}
+
+func envName(key string) string {
+	return "MEETKAT_" + strings.ToUpper(key)
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// parse sets the setting from its string form, as used in environment
+// variables and flags. Lists are comma-separated.
+func (s setting) parse(v string) error {
+	switch p := s.ptr.(type) {
+	case *string:
+		*p = v
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q (use true or false)", v)
+		}
+		*p = b
+	case *float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		*p = f
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q (use e.g. 90s, 5m or 24h)", v)
+		}
+		*p = d
+	case *[]string:
+		*p = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("config: unsupported setting type %T", s.ptr))
+	}
+	return nil
+}
+
+// set assigns a value decoded from TOML.
+func (s setting) set(v any) error {
+	switch p := s.ptr.(type) {
+	case *int:
+		n, ok := v.(int64)
+		if !ok {
+			return fmt.Errorf("expected an integer, got %v", v)
+		}
+		*p = int(n)
+	case *bool:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("expected true or false, got %v", v)
+		}
+		*p = b
+	case *float64:
+		switch f := v.(type) {
+		case float64:
+			*p = f
+		case int64:
+			*p = float64(f)
+		default:
+			return fmt.Errorf("expected a number, got %v", v)
+		}
+	case *[]string:
+		items, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("expected a list of strings, got %v", v)
+		}
+		*p = nil
+		for _, item := range items {
+			str, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("expected a list of strings, got %v", v)
+			}
+			*p = append(*p, str)
+		}
+	default:
+		// Strings and durations ("5m") are written as TOML strings.
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %v", v)
+		}
+		return s.parse(str)
+	}
+	return nil
+}
+
+// loadFile applies the settings in the TOML file at path.
+func (c *Config) loadFile(path string, settings []setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	var values map[string]any
+	if err := toml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+	for key, v := range values {
+		s, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		if err := s.set(v); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+		c.sources[key] = "file " + path
+	}
+	return nil
+}
+
+// WriteTOML writes the effective configuration as a TOML config file, with
+// each setting's description and, if it is not a default, its source.
+func (c Config) WriteTOML(w io.Writer) error {
+	var buf bytes.Buffer
+	for _, s := range c.settings() {
+		var v any
+		switch p := s.ptr.(type) {
+		case *string:
+			v = *p
+			if secretKeys[s.key] && *p != "" {
+				v = "(redacted)"
+			}
+		case *int:
+			v = *p
+		case *bool:
+			v = *p
+		case *float64:
+			v = *p
+		case *time.Duration:
+			v = p.String()
+		case *[]string:
+			v = *p
+			if *p == nil {
+				v = []string{}
+			}
+		}
+		line, err := toml.Marshal(map[string]any{s.key: v})
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", s.key, err)
+		}
+		fmt.Fprintf(&buf, "# %s", s.usage)
+		if src := c.sources[s.key]; src != "" {
+			fmt.Fprintf(&buf, " [from %s]", src)
+		}
+		buf.WriteString("\n")
+		buf.Write(line)
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// rawFlag collects the string value of a flag so that flags can be applied
+// after the config file and environment.
+type rawFlag struct {
+	key    string
+	values map[string]string
+	isBool bool
+}
+
+// IsBoolFlag lets boolean settings be given as a bare -flag.
+func (f *rawFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+func (f *rawFlag) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return f.values[f.key]
+}
+
+func (f *rawFlag) Set(v string) error {
+	f.values[f.key] = v
+	return nil
+}
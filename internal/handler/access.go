@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"meetkat/internal/middleware"
+	"meetkat/internal/poll"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accessTTL is how long a password-protected poll stays unlocked in a
+// browser after the password was entered.
+const accessTTL = 7 * 24 * time.Hour
+
+// votedTTL is how long a browser is remembered as having voted, which
+// reveals the results of polls using poll.ResultsAfterVote.
+const votedTTL = 365 * 24 * time.Hour
+
+// CookieSetter writes an HttpOnly cookie with the site's cookie attributes.
+type CookieSetter func(c *gin.Context, name, value string, maxAge int)
+
+// SetAccessCookies configures the cookies that remember an unlocked
+// password-protected poll or a cast vote: key signs them and set writes them. Without it,
+// a random per-process key and host-only cookies are used. It must be called
+// before the handler is used.
+func (h *PollHandler) SetAccessCookies(key []byte, set CookieSetter) {
+	h.accessKey = key
+	h.setCookie = set
+}
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key) // never fails, see crypto/rand.Read
+	return key
+}
+
+func defaultSetCookie(c *gin.Context, name, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, appPath(c, "/"), "", middleware.IsHTTPS(c), true)
+}
+
+func accessCookieName(pollID string) string {
+	return "meetkat_poll_" + pollID
+}
+
+func votedCookieName(pollID string) string {
+	return "meetkat_voted_" + pollID
+}
+
+// sign returns the base64 HMAC of the lines of data under the access key.
+func (h *PollHandler) sign(data ...string) string {
+	mac := hmac.New(sha256.New, h.accessKey)
+	_, _ = mac.Write([]byte(strings.Join(data, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// accessToken signs the poll ID and expiry. The password hash is part of the
+// signed data, so changing the password ends every existing session.
+func (h *PollHandler) accessToken(p *poll.Poll, expires int64) string {
+	exp := strconv.FormatInt(expires, 10)
+	return exp + "." + h.sign(p.ID, exp, p.PasswordHash)
+}
+
+// hasAccess reports whether the request may view and vote on p: always for
+// polls without a password, otherwise only with a valid access cookie.
+func (h *PollHandler) hasAccess(c *gin.Context, p *poll.Poll) bool {
+	if p.PasswordHash == "" {
+		return true
+	}
+	value, err := c.Cookie(accessCookieName(p.ID))
+	if err != nil {
+		return false
+	}
+	expires, _, _ := strings.Cut(value, ".")
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(value), []byte(h.accessToken(p, exp)))
+}
+
+// votedToken signs the poll ID and the name the vote is stored under, so a
+// voted cookie stands for one vote and ends when that vote is removed or
+// renamed.
+func (h *PollHandler) votedToken(p *poll.Poll, name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name)) + "." + h.sign("voted", p.ID, name)
+}
+
+// markVoted remembers that this browser cast the vote stored as name on p,
+// for polls whose results are only shown to participants who have voted.
+func (h *PollHandler) markVoted(c *gin.Context, p *poll.Poll, name string) {
+	c.Set("voted", true)
+	if p.ResultsVisibility == poll.ResultsAfterVote {
+		h.setCookie(c, votedCookieName(p.ID), h.votedToken(p, name), int(votedTTL.Seconds()))
+	}
+}
+
+// hasVoted reports whether the request comes from a browser whose vote on p
+// still exists.
+//
+// The voted cookie only gates the results of poll.ResultsAfterVote polls for
+// participants; it is not access control. Anyone who casts a vote, under any
+// name, sees the results, and a cookie handed to another browser works there
+// too. Polls whose results must stay private should use
+// poll.ResultsAfterClose or poll.ResultsAdminOnly.
+func (h *PollHandler) hasVoted(c *gin.Context, p *poll.Poll) bool {
+	if c.GetBool("voted") {
+		return true
+	}
+	value, err := c.Cookie(votedCookieName(p.ID))
+	if err != nil {
+		return false
+	}
+	encoded, _, _ := strings.Cut(value, ".")
+	name, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !hmac.Equal([]byte(value), []byte(h.votedToken(p, string(name)))) {
+		return false
+	}
+	for _, v := range p.Votes {
+		if v.Name == string(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *PollHandler) renderUnlock(c *gin.Context, p *poll.Poll, wrongPassword bool) {
+	loc := LocalizerFromCtx(c)
+	c.Header("Cache-Control", "no-store")
+	renderHTML(h.tmpls, c, http.StatusForbidden, "unlock.html", gin.H{
+		"title":         loc.T("unlock.page_title"),
+		"pollID":        p.ID,
+		"wrongPassword": wrongPassword,
+	})
+}
+
+// UnlockPoll checks the password of a protected poll and, if it matches,
+// sets an access cookie and returns to the poll. Attempts are throttled by
+// the rate limiter on the route.
+func (h *PollHandler) UnlockPoll(c *gin.Context) {
+	id := c.Param("id")
+
+	p, ok := h.mustLoadPoll(c, id, false)
+	if !ok {
+		return
+	}
+	if p.PasswordHash == "" {
+		c.Redirect(http.StatusSeeOther, appPath(c, fmt.Sprintf("/poll/%s", p.ID)))
+		return
+	}
+
+	if !poll.CheckPassword(p, c.PostForm("password")) {
+		LoggerFromCtx(c).Info("wrong poll password", "poll", p.ID)
+		h.renderUnlock(c, p, true)
+		return
+	}
+
+	expires := time.Now().Add(accessTTL).Unix()
+	h.setCookie(c, accessCookieName(p.ID), h.accessToken(p, expires), int(accessTTL.Seconds()))
+	c.Redirect(http.StatusSeeOther, appPath(c, fmt.Sprintf("/poll/%s", p.ID)))
+}
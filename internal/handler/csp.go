@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"cmp"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"meetkat/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCSPReportSize bounds the body of a violation report; browsers send a
+// few hundred bytes per violation.
+const maxCSPReportSize = 64 << 10
+
+// cspViolation holds the fields of a violation report worth logging. The
+// legacy report-uri format uses kebab-case keys, the Reporting API camelCase.
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	DocumentURL        string `json:"documentURL"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	BlockedURI         string `json:"blocked-uri"`
+	BlockedURL         string `json:"blockedURL"`
+	SourceFile         string `json:"source-file"`
+	SourceFileAPI      string `json:"sourceFile"`
+	LineNumber         int    `json:"line-number"`
+	LineNumberAPI      int    `json:"lineNumber"`
+	Sample             string `json:"script-sample"`
+	SampleAPI          string `json:"sample"`
+}
+
+// CSPReport logs Content-Security-Policy violation reports, sent by browsers
+// either as a single {"csp-report": {...}} object (report-uri) or as a list
+// of reports (Reporting API, report-to). The URLs in a report point at the
+// page that caused it, so admin IDs in them are redacted like in the access
+// log.
+func CSPReport(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxCSPReportSize))
+	if err != nil {
+		c.Status(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var violations []cspViolation
+	var legacy struct {
+		Report *cspViolation `json:"csp-report"`
+	}
+	var reports []struct {
+		Type string       `json:"type"`
+		Body cspViolation `json:"body"`
+	}
+	switch {
+	case json.Unmarshal(body, &legacy) == nil && legacy.Report != nil:
+		violations = append(violations, *legacy.Report)
+	case json.Unmarshal(body, &reports) == nil:
+		for _, r := range reports {
+			if r.Type == "csp-violation" {
+				violations = append(violations, r.Body)
+			}
+		}
+	default:
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	logger := LoggerFromCtx(c)
+	for _, v := range violations {
+		logger.Warn("content security policy violation",
+			"document", redactURI(cmp.Or(v.DocumentURI, v.DocumentURL)),
+			"directive", cmp.Or(v.EffectiveDirective, v.ViolatedDirective),
+			"blocked", redactURI(cmp.Or(v.BlockedURI, v.BlockedURL)),
+			"source", redactURI(cmp.Or(v.SourceFile, v.SourceFileAPI)),
+			"line", max(v.LineNumber, v.LineNumberAPI),
+			"sample", cmp.Or(v.Sample, v.SampleAPI),
+		)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// redactURI redacts the admin ID in the path of a reported URL and drops its
+// query and fragment. Values that are not URLs, such as "inline", are kept.
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return middleware.RedactPath(uri)
+	}
+	u.Path = middleware.RedactPath(u.Path)
+	u.RawPath = ""
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"meetkat/internal/i18n"
+	"meetkat/internal/middleware"
+	"meetkat/internal/poll"
+	"meetkat/internal/view"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPagesCarryCSPNonce(t *testing.T) {
+	tr, err := i18n.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewPollHandler(poll.NewService(poll.NewMemoryRepository()), view.LoadTemplates("../.."))
+	r := gin.New()
+	r.Use(middleware.SecurityHeaders("/csp-report"))
+	r.Use(middleware.LangCookie(tr, middleware.DefaultCookieConfig()))
+	r.GET("/new", h.ShowNew)
+
+	nonces := map[string]bool{}
+	for range 2 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/new", nil))
+
+		csp := w.Header().Get("Content-Security-Policy")
+		m := regexp.MustCompile(`script-src 'self' 'nonce-([\w-]+)';`).FindStringSubmatch(csp)
+		if m == nil || strings.Contains(csp, "script-src 'self' 'unsafe-inline'") {
+			t.Fatalf("CSP without script nonce: %s", csp)
+		}
+		nonces[m[1]] = true
+		scripts := strings.Count(w.Body.String(), "<script")
+		withNonce := strings.Count(w.Body.String(), `nonce="`+m[1]+`"`)
+		external := strings.Count(w.Body.String(), "<script src=")
+		if scripts != withNonce+external {
+			t.Errorf("%d scripts, %d with nonce, %d external", scripts, withNonce, external)
+		}
+		if strings.Contains(w.Body.String(), " onclick=") {
+			t.Error("inline event handler would be blocked by the CSP")
+		}
+	}
+	if len(nonces) != 2 {
+		t.Error("nonce reused across requests")
+	}
+}
+
+func TestCSPReport(t *testing.T) {
+	var buf bytes.Buffer
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("logger", slog.New(slog.NewTextHandler(&buf, nil)))
+	})
+	r.POST("/csp-report", CSPReport)
+
+	tests := []struct {
+		name, contentType, body string
+		want                    int
+		logged                  string
+	}{
+		{"report-uri", "application/csp-report",
+			`{"csp-report":{"document-uri":"https://meet.example.org/new","violated-directive":"script-src-elem","blocked-uri":"inline","line-number":15}}`,
+			http.StatusNoContent, "directive=script-src-elem blocked=inline source=\"\" line=15"},
+		{"report-to", "application/reports+json",
+			`[{"type":"csp-violation","body":{"documentURL":"https://meet.example.org/","effectiveDirective":"script-src-elem","blockedURL":"https://cdn.example.com/x.js","sourceFile":"https://meet.example.org/","lineNumber":3}},{"type":"deprecation","body":{}}]`,
+			http.StatusNoContent, "blocked=https://cdn.example.com/x.js source=https://meet.example.org/ line=3"},
+		{"garbage", "application/json", `not json`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if got := strings.Count(buf.String(), "content security policy violation"); tt.logged != "" && got != 1 {
+			t.Errorf("%s: logged %d violations, want 1: %s", tt.name, got, buf.String())
+		}
+		if !strings.Contains(buf.String(), tt.logged) {
+			t.Errorf("%s: log %q does not contain %q", tt.name, buf.String(), tt.logged)
+		}
+	}
+}
+
+func TestCSPReportRedactsAdminID(t *testing.T) {
+	var buf bytes.Buffer
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("logger", slog.New(slog.NewTextHandler(&buf, nil)))
+	})
+	r.POST("/csp-report", CSPReport)
+
+	for _, body := range []string{
+		`{"csp-report":{"document-uri":"https://meet.example.org/poll/secretadmin/admin","violated-directive":"script-src-elem","blocked-uri":"https://meet.example.org/meetkat/poll/secretadmin/admin/export?x=1","source-file":"https://meet.example.org/poll/secretadmin/admin"}}`,
+		`[{"type":"csp-violation","body":{"documentURL":"https://meet.example.org/poll/secretadmin/admin#votes","effectiveDirective":"script-src-elem","blockedURL":"inline"}}]`,
+	} {
+		buf.Reset()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(body)))
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+		}
+		if strings.Contains(buf.String(), "secretadmin") {
+			t.Errorf("log contains the admin ID: %s", buf.String())
+		}
+		if !strings.Contains(buf.String(), "document=https://meet.example.org/poll/REDACTED/admin") {
+			t.Errorf("log does not contain the redacted document URL: %s", buf.String())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveHealth(h *HealthHandler, path string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.GET("/healthz", h.Live)
+	r.GET("/readyz", h.Ready)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestHealthLive(t *testing.T) {
+	h := NewHealthHandler(HealthCheck{Name: "database", Check: func(context.Context) error {
+		return errors.New("down")
+	}})
+	if w := serveHealth(h, "/healthz"); w.Code != http.StatusOK {
+		t.Fatalf("expected liveness to ignore checks, got %d", w.Code)
+	}
+}
+
+func TestHealthReady(t *testing.T) {
+	dbErr := error(nil)
+	h := NewHealthHandler(
+		HealthCheck{Name: "database", Check: func(context.Context) error { return dbErr }},
+		HealthCheck{Name: "templates", Check: func(context.Context) error { return nil }},
+	)
+
+	if w := serveHealth(h, "/readyz"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 while checks pass, got %d", w.Code)
+	}
+
+	dbErr = errors.New("database is locked")
+	w := serveHealth(h, "/readyz")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with failing check, got %d", w.Code)
+	}
+	var body struct {
+		Checks map[string]string `json:"checks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Checks["database"] != "fail" || body.Checks["templates"] != "ok" {
+		t.Errorf("unexpected checks %v", body.Checks)
+	}
+}
+
+func TestHealthReadyFailsWhileDraining(t *testing.T) {
+	h := NewHealthHandler()
+	h.SetDraining()
+
+	if w := serveHealth(h, "/readyz"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while draining, got %d", w.Code)
+	}
+	if w := serveHealth(h, "/healthz"); w.Code != http.StatusOK {
+		t.Fatalf("expected liveness to stay up while draining, got %d", w.Code)
+	}
+}
+
+func TestCachedCheck(t *testing.T) {
+	calls := 0
+	err := errors.New("pending migrations")
+	check := CachedCheck(time.Hour, func(context.Context) error {
+		calls++
+		return err
+	})
+	for range 3 {
+		if got := check(context.Background()); got != err {
+			t.Fatalf("got %v, want the cached error", got)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("check ran %d times within the ttl, want 1", calls)
+	}
+
+	calls = 0
+	uncached := CachedCheck(0, func(context.Context) error {
+		calls++
+		return nil
+	})
+	_ = uncached(context.Background())
+	_ = uncached(context.Background())
+	if calls != 2 {
+		t.Errorf("check ran %d times with ttl 0, want 2", calls)
+	}
+}
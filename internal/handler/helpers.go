@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"meetkat/internal/i18n"
+	"meetkat/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,6 +16,35 @@ func LocalizerFromCtx(c *gin.Context) *i18n.Localizer {
 	return c.MustGet("localizer").(*i18n.Localizer)
 }
 
+// LoggerFromCtx returns the request-scoped logger set by the request logging
+// middleware, or the default logger when it is not installed.
+func LoggerFromCtx(c *gin.Context) *slog.Logger {
+	if l, ok := c.Get("logger"); ok {
+		return l.(*slog.Logger)
+	}
+	return slog.Default()
+}
+
+// appPath prefixes an absolute path within the app with the base path set by
+// the base path middleware, e.g. "/poll/x" becomes "/meetkat/poll/x".
+func appPath(c *gin.Context, path string) string {
+	return c.GetString("base_path") + path
+}
+
+// absURL returns the absolute URL of a path within the app. It uses the
+// configured public base URL; only when none is set is the URL derived from
+// the request's scheme and Host header, which clients control.
+func absURL(c *gin.Context, path string) string {
+	if base := c.GetString("base_url"); base != "" {
+		return base + path
+	}
+	scheme := "http"
+	if middleware.IsHTTPS(c) {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + appPath(c, path)
+}
+
 // isAJAX returns true when the request was made via fetch() with our custom header.
 func isAJAX(c *gin.Context) bool {
 	return c.GetHeader("X-Requested-With") == "fetch"
@@ -37,6 +67,10 @@ func prepareResponse(c *gin.Context, code int, data gin.H) {
 	data["t"] = loc.T
 	data["lang"] = loc.Lang()
 	data["csrf_token"] = c.GetString("csrf_token")
+	data["csp_nonce"] = c.GetString("csp_nonce")
+	data["form_token"] = c.GetString("form_token")
+	data["pow_bits"] = c.GetInt("pow_bits")
+	data["base"] = c.GetString("base_path")
 	c.Status(code)
 	c.Header("Content-Type", "text/html; charset=utf-8")
 }
@@ -52,7 +86,7 @@ func renderFragment(tmpls map[string]*template.Template, c *gin.Context, pageNam
 	}
 	prepareResponse(c, http.StatusOK, data)
 	if err := tmpl.ExecuteTemplate(c.Writer, fragmentName, data); err != nil {
-		slog.Error("fragment render error", "err", err)
+		LoggerFromCtx(c).Error("fragment render error", "err", err)
 	}
 }
 
@@ -65,6 +99,6 @@ func renderHTML(tmpls map[string]*template.Template, c *gin.Context, code int, n
 	}
 	prepareResponse(c, code, data)
 	if err := tmpl.ExecuteTemplate(c.Writer, name, data); err != nil {
-		slog.Error("template render error", "err", err)
+		LoggerFromCtx(c).Error("template render error", "err", err)
 	}
 }
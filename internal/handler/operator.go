@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"meetkat/internal/poll"
+
+	"github.com/gin-gonic/gin"
+)
+
+// operatorPageSize is the number of polls per page in the operator console.
+const operatorPageSize = 50
+
+// OperatorHandler serves the operator console, which lists every poll on
+// the instance and lets the operator delete abusive polls and votes. It does
+// no access control of its own; see middleware.OperatorAuth.
+type OperatorHandler struct {
+	svc   *poll.Service
+	tmpls map[string]*template.Template
+}
+
+func NewOperatorHandler(svc *poll.Service, tmpls map[string]*template.Template) *OperatorHandler {
+	return &OperatorHandler{svc: svc, tmpls: tmpls}
+}
+
+// ListPolls shows a page of polls, newest first, optionally filtered by the
+// title search q.
+func (h *OperatorHandler) ListPolls(c *gin.Context) {
+	loc := LocalizerFromCtx(c)
+	query := strings.TrimSpace(c.Query("q"))
+	page, _ := strconv.Atoi(c.Query("page"))
+	page = max(page, 1)
+
+	// One extra poll tells whether there is a next page.
+	polls, err := h.svc.ListPolls(c.Request.Context(), query, (page-1)*operatorPageSize, operatorPageSize+1)
+	if err != nil {
+		LoggerFromCtx(c).Error("list polls error", "err", err)
+		c.String(http.StatusInternalServerError, loc.T("error.generic"))
+		return
+	}
+
+	pageURL := func(p int) string {
+		v := url.Values{}
+		if query != "" {
+			v.Set("q", query)
+		}
+		if p > 1 {
+			v.Set("page", strconv.Itoa(p))
+		}
+		if len(v) == 0 {
+			return appPath(c, "/operator")
+		}
+		return appPath(c, "/operator?"+v.Encode())
+	}
+	data := gin.H{
+		"title": loc.T("operator.page_title"),
+		"query": query,
+	}
+	if len(polls) > operatorPageSize {
+		polls = polls[:operatorPageSize]
+		data["nextPage"] = pageURL(page + 1)
+	}
+	if page > 1 {
+		data["prevPage"] = pageURL(page - 1)
+	}
+	data["polls"] = polls
+	if c.Query("deleted") == "1" {
+		data["notice"] = loc.T("operator.deleted_notice")
+	}
+	c.Header("Cache-Control", "no-store")
+	renderHTML(h.tmpls, c, http.StatusOK, "operator.html", data)
+}
+
+// ShowPoll shows a poll with its votes.
+func (h *OperatorHandler) ShowPoll(c *gin.Context) {
+	loc := LocalizerFromCtx(c)
+	p, ok := h.loadPoll(c)
+	if !ok {
+		return
+	}
+
+	data := gin.H{
+		"title":     fmt.Sprintf(loc.T("operator.poll_page_title"), p.Title),
+		"poll":      p,
+		"maskNames": p.NameVisibility == poll.NamesHidden,
+	}
+	if c.Query("removed") == "1" {
+		data["notice"] = loc.T("operator.removed_notice")
+	}
+	c.Header("Cache-Control", "no-store")
+	renderHTML(h.tmpls, c, http.StatusOK, "operator_poll.html", data)
+}
+
+// DeletePoll deletes a poll with all its votes.
+func (h *OperatorHandler) DeletePoll(c *gin.Context) {
+	loc := LocalizerFromCtx(c)
+	p, ok := h.loadPoll(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(c.Request.Context(), p.ID); err != nil {
+		LoggerFromCtx(c).Error("delete poll error", "err", err)
+		c.String(http.StatusInternalServerError, loc.T("error.generic"))
+		return
+	}
+	LoggerFromCtx(c).Info("poll deleted by operator", "poll", p.ID)
+
+	c.Redirect(http.StatusSeeOther, appPath(c, "/operator?deleted=1"))
+}
+
+// RemoveVote removes the vote of the voter given as "name".
+func (h *OperatorHandler) RemoveVote(c *gin.Context) {
+	loc := LocalizerFromCtx(c)
+	p, ok := h.loadPoll(c)
+	if !ok {
+		return
+	}
+
+	name := c.PostForm("name")
+	if err := h.svc.RemoveVote(c.Request.Context(), p.ID, name); err != nil {
+		LoggerFromCtx(c).Error("remove vote error", "err", err)
+		c.String(http.StatusInternalServerError, loc.T("error.generic"))
+		return
+	}
+	LoggerFromCtx(c).Info("vote removed by operator", "poll", p.ID)
+
+	c.Redirect(http.StatusSeeOther, appPath(c, fmt.Sprintf("/operator/poll/%s?removed=1", p.ID)))
+}
+
+// loadPoll fetches the poll named by the id parameter, writing the error or
+// not-found response itself when there is none.
+func (h *OperatorHandler) loadPoll(c *gin.Context) (*poll.Poll, bool) {
+	loc := LocalizerFromCtx(c)
+	p, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		LoggerFromCtx(c).Error("load poll error", "err", err)
+		c.String(http.StatusInternalServerError, loc.T("error.generic"))
+		return nil, false
+	}
+	if p == nil {
+		renderHTML(h.tmpls, c, http.StatusNotFound, "404.html", gin.H{
+			"title": loc.T("notfound.page_title"),
+		})
+		return nil, false
+	}
+	return p, true
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"meetkat/internal/i18n"
+	"meetkat/internal/middleware"
+	"meetkat/internal/poll"
+	"meetkat/internal/view"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupOperatorRouter() (*gin.Engine, *poll.Service) {
+	tr, err := i18n.New()
+	if err != nil {
+		panic(err)
+	}
+	svc := poll.NewService(poll.NewMemoryRepository())
+	h := NewOperatorHandler(svc, view.LoadTemplates("../.."))
+
+	r := gin.New()
+	r.Use(middleware.LangCookie(tr, middleware.DefaultCookieConfig()))
+	r.GET("/operator", h.ListPolls)
+	r.GET("/operator/poll/:id", h.ShowPoll)
+	r.POST("/operator/poll/:id/delete", h.DeletePoll)
+	r.POST("/operator/poll/:id/remove", h.RemoveVote)
+	return r, svc
+}
+
+func getPage(router http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestOperatorListPolls(t *testing.T) {
+	router, svc := setupOperatorRouter()
+	seedPoll(svc, "Team lunch", []string{"2025-03-10"})
+	spam := seedPoll(svc, "Cheap watches", []string{"2025-03-10"})
+	if err := svc.AddVote(context.Background(), spam.ID, "Bot", map[string]string{"2025-03-10": "yes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := getPage(router, "/operator")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "Team lunch") || !strings.Contains(body, "Cheap watches") {
+		t.Error("list does not show all polls")
+	}
+	if strings.Index(body, "Cheap watches") > strings.Index(body, "Team lunch") {
+		t.Error("newest poll is not listed first")
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Error("operator page may be cached")
+	}
+
+	body = getPage(router, "/operator?q=WATCH").Body.String()
+	if !strings.Contains(body, "Cheap watches") || strings.Contains(body, "Team lunch") {
+		t.Error("search does not filter by title")
+	}
+}
+
+func TestOperatorListPollsPagination(t *testing.T) {
+	router, svc := setupOperatorRouter()
+	for i := range operatorPageSize + 1 {
+		seedPoll(svc, fmt.Sprintf("Poll %03d", i), []string{"2025-03-10"})
+	}
+
+	body := getPage(router, "/operator?q=poll").Body.String()
+	if strings.Contains(body, "Poll 000") || !strings.Contains(body, "Poll 001") {
+		t.Error("first page does not hold the newest polls")
+	}
+	if !strings.Contains(body, `href="/operator?page=2&amp;q=poll"`) {
+		t.Error("first page links no next page")
+	}
+
+	body = getPage(router, "/operator?q=poll&page=2").Body.String()
+	if !strings.Contains(body, "Poll 000") || strings.Contains(body, "Poll 001") {
+		t.Error("second page does not hold the oldest poll")
+	}
+	if !strings.Contains(body, `href="/operator?q=poll"`) || strings.Contains(body, "page=3") {
+		t.Error("second page links wrong neighbours")
+	}
+}
+
+func TestOperatorShowPoll(t *testing.T) {
+	router, svc := setupOperatorRouter()
+	p := seedPoll(svc, "Team lunch", []string{"2025-03-10"})
+	if err := svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"2025-03-10": "yes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := getPage(router, "/operator/poll/"+p.ID)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Alice") {
+		t.Errorf("status = %d, votes not shown", w.Code)
+	}
+	if w := getPage(router, "/operator/poll/nonexistent"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown poll: status = %d, want 404", w.Code)
+	}
+}
+
+func TestOperatorDeletePoll(t *testing.T) {
+	router, svc := setupOperatorRouter()
+	p := seedPoll(svc, "Cheap watches", []string{"2025-03-10"})
+
+	w := postForm(router, "/operator/poll/"+p.ID+"/delete", url.Values{})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/operator?deleted=1" {
+		t.Fatalf("status = %d, location = %q", w.Code, w.Header().Get("Location"))
+	}
+	if got, _ := svc.Get(context.Background(), p.ID); got != nil {
+		t.Error("poll still exists")
+	}
+	if !strings.Contains(getPage(router, "/operator?deleted=1").Body.String(), "The poll has been deleted.") {
+		t.Error("no deletion notice")
+	}
+}
+
+func TestOperatorRemoveVote(t *testing.T) {
+	router, svc := setupOperatorRouter()
+	p := seedPoll(svc, "Team lunch", []string{"2025-03-10"})
+	for _, name := range []string{"Alice", "Spammer"} {
+		if err := svc.AddVote(context.Background(), p.ID, name, map[string]string{"2025-03-10": "yes"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := postForm(router, "/operator/poll/"+p.ID+"/remove", url.Values{"name": {"Spammer"}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/operator/poll/"+p.ID+"?removed=1" {
+		t.Fatalf("status = %d, location = %q", w.Code, w.Header().Get("Location"))
+	}
+	got, _ := svc.Get(context.Background(), p.ID)
+	if len(got.Votes) != 1 || got.Votes[0].Name != "Alice" {
+		t.Errorf("votes = %+v, want only Alice", got.Votes)
+	}
+}
@@ -3,12 +3,13 @@ package handler
 import (
 	"fmt"
 	"html/template"
-	"log/slog"
 	"net/http"
 	"sort"
 	"strings"
 	"time"
 
+	"meetkat/internal/archive"
+	"meetkat/internal/metrics"
 	"meetkat/internal/poll"
 	"meetkat/internal/view"
 
@@ -18,10 +19,15 @@ import (
 type PollHandler struct {
 	svc   *poll.Service
 	tmpls map[string]*template.Template
+
+	// accessKey signs the cookies of unlocked password-protected polls,
+	// which setCookie writes; see SetAccessCookies.
+	accessKey []byte
+	setCookie CookieSetter
 }
 
 func NewPollHandler(svc *poll.Service, tmpls map[string]*template.Template) *PollHandler {
-	return &PollHandler{svc: svc, tmpls: tmpls}
+	return &PollHandler{svc: svc, tmpls: tmpls, accessKey: randomKey(), setCookie: defaultSetCookie}
 }
 
 // respondAfterMutation re-fetches the poll via loadPoll and renders the
@@ -42,15 +48,41 @@ func (h *PollHandler) respondAfterMutation(
 
 // renderVoteTable renders only the vote_table fragment for AJAX responses.
 func (h *PollHandler) renderVoteTable(c *gin.Context, p *poll.Poll, isAdmin bool, pageName string) {
-	totals := poll.Totals(p)
-	renderFragment(h.tmpls, c, pageName, "vote_table", gin.H{
+	renderFragment(h.tmpls, c, pageName, "vote_table", h.voteTableData(c, p, isAdmin))
+}
+
+// voteTableData returns the template data used by the vote_table fragment.
+// Votes and totals are left out unless the poll's results visibility lets
+// this request see them, and participants of anonymous polls only get the
+// totals.
+func (h *PollHandler) voteTableData(c *gin.Context, p *poll.Poll, isAdmin bool) gin.H {
+	loc := LocalizerFromCtx(c)
+	showResults := isAdmin || p.ResultsVisible(h.hasVoted(c, p))
+	data := gin.H{
 		"poll":         p,
-		"totals":       totals,
-		"winners":      view.WinningOptions(totals),
 		"isAdmin":      isAdmin,
+		"closed":       p.Closed(),
 		"answerMode":   p.AnswerMode,
-		"headerGroups": view.BuildDateHeaders(p.Options, LocalizerFromCtx(c).T),
-	})
+		"headerGroups": poll.Derive(p, "headers."+loc.Lang(), func() []view.HeaderGroup { return view.BuildDateHeaders(p.Options, loc.T) }),
+		"showResults":  showResults,
+		"showVotes":    isAdmin || !p.Anonymous(),
+		"maskNames":    p.NameVisibility == poll.NamesHidden,
+	}
+	if showResults {
+		totals := poll.Derive(p, "totals", func() map[string]poll.OptionTotal { return poll.Totals(p) })
+		data["totals"] = totals
+		data["winners"] = poll.Derive(p, "winners", func() map[string]bool { return view.WinningOptions(totals) })
+		return data
+	}
+	switch p.ResultsVisibility {
+	case poll.ResultsAfterVote:
+		data["resultsNotice"] = loc.T("poll.results_hidden_after_vote")
+	case poll.ResultsAfterClose:
+		data["resultsNotice"] = loc.T("poll.results_hidden_after_close")
+	default:
+		data["resultsNotice"] = loc.T("poll.results_hidden_admin_only")
+	}
+	return data
 }
 
 func (h *PollHandler) ShowNew(c *gin.Context) {
@@ -67,6 +99,9 @@ func (h *PollHandler) CreatePoll(c *gin.Context) {
 	loc := LocalizerFromCtx(c)
 	title := strings.TrimSpace(c.PostForm("title"))
 	description := strings.TrimSpace(c.PostForm("description"))
+	password := c.PostForm("password")
+	resultsVisibility := c.PostForm("results_visibility")
+	nameVisibility := c.PostForm("name_visibility")
 	dates := c.PostFormArray("dates[]")
 
 	var options []string
@@ -84,16 +119,12 @@ func (h *PollHandler) CreatePoll(c *gin.Context) {
 	if len(options) == 0 {
 		errors = append(errors, loc.T("new.error_no_dates"))
 	}
+	if len(password) > poll.MaxPasswordLen {
+		errors = append(errors, fmt.Sprintf(loc.T("new.error_password_too_long"), poll.MaxPasswordLen))
+	}
 
 	if len(errors) > 0 {
-		renderHTML(h.tmpls, c, http.StatusUnprocessableEntity, "new.html", gin.H{
-			"title":           loc.T("new.page_title"),
-			"errors":          errors,
-			"formTitle":       title,
-			"formDescription": description,
-			"formDates":       dates,
-			"formAnswerMode":  c.PostForm("answer_mode"),
-		})
+		h.renderNewForm(c, http.StatusUnprocessableEntity, errors)
 		return
 	}
 
@@ -101,13 +132,45 @@ func (h *PollHandler) CreatePoll(c *gin.Context) {
 
 	answerMode := c.PostForm("answer_mode")
 
-	p, err := h.svc.Create(title, description, answerMode, options)
+	p, err := h.svc.Create(c.Request.Context(), title, description, answerMode, options, poll.Settings{
+		Password:          password,
+		ResultsVisibility: resultsVisibility,
+		NameVisibility:    nameVisibility,
+	})
 	if err != nil {
-		slog.Error("create poll error", "err", err)
+		LoggerFromCtx(c).Error("create poll error", "err", err)
 		c.String(http.StatusInternalServerError, loc.T("error.generic"))
 		return
 	}
-	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/poll/%s/admin", p.AdminID))
+	// Counted here rather than in the repository so that polls stored by
+	// "meetkat import" are not taken for new ones.
+	metrics.PollsCreated.Inc()
+	// Only a hash of the admin ID is stored, so this redirect is the one
+	// chance to show the organizer their admin link; created=1 makes the
+	// admin page say so.
+	c.Redirect(http.StatusSeeOther, appPath(c, fmt.Sprintf("/poll/%s/admin?created=1", p.AdminID)))
+}
+
+// RejectNewPoll answers a poll creation that the spam guard rejected by
+// re-rendering the filled-in form with an error.
+func (h *PollHandler) RejectNewPoll(c *gin.Context) {
+	h.renderNewForm(c, http.StatusBadRequest, []string{LocalizerFromCtx(c).T("error.spam_rejected")})
+}
+
+// renderNewForm re-renders the new poll form with the submitted values and
+// the given errors.
+func (h *PollHandler) renderNewForm(c *gin.Context, code int, errors []string) {
+	loc := LocalizerFromCtx(c)
+	renderHTML(h.tmpls, c, code, "new.html", gin.H{
+		"title":           loc.T("new.page_title"),
+		"errors":          errors,
+		"formTitle":       strings.TrimSpace(c.PostForm("title")),
+		"formDescription": strings.TrimSpace(c.PostForm("description")),
+		"formDates":       c.PostFormArray("dates[]"),
+		"formAnswerMode":  c.PostForm("answer_mode"),
+		"formResults":     c.PostForm("results_visibility"),
+		"formNames":       c.PostForm("name_visibility"),
+	})
 }
 
 func (h *PollHandler) renderNotFound(c *gin.Context) {
@@ -127,12 +190,12 @@ func (h *PollHandler) mustLoadPoll(c *gin.Context, id string, isAdmin bool) (*po
 	var p *poll.Poll
 	var err error
 	if isAdmin {
-		p, err = h.svc.GetByAdminID(id)
+		p, err = h.svc.GetByAdminID(c.Request.Context(), id)
 	} else {
-		p, err = h.svc.Get(id)
+		p, err = h.svc.Get(c.Request.Context(), id)
 	}
 	if err != nil {
-		slog.Error("load poll error", "err", err)
+		LoggerFromCtx(c).Error("load poll error", "err", err)
 		loc := LocalizerFromCtx(c)
 		c.String(http.StatusInternalServerError, loc.T("error.generic"))
 		return nil, false
@@ -152,18 +215,18 @@ func (h *PollHandler) ShowPoll(c *gin.Context) {
 	if !ok {
 		return
 	}
+	if !h.hasAccess(c, p) {
+		h.renderUnlock(c, p, false)
+		return
+	}
+	if p.PasswordHash != "" || p.ResultsVisibility == poll.ResultsAfterVote {
+		c.Header("Cache-Control", "no-store")
+	}
 
-	totals := poll.Totals(p)
-	renderHTML(h.tmpls, c, http.StatusOK, "poll.html", gin.H{
-		"title":        fmt.Sprintf(loc.T("poll.page_title"), p.Title),
-		"poll":         p,
-		"totals":       totals,
-		"winners":      view.WinningOptions(totals),
-		"url":          fmt.Sprintf("%s/poll/%s", c.Request.Host, p.ID),
-		"isAdmin":      false,
-		"answerMode":   p.AnswerMode,
-		"headerGroups": view.BuildDateHeaders(p.Options, loc.T),
-	})
+	data := h.voteTableData(c, p, false)
+	data["title"] = fmt.Sprintf(loc.T("poll.page_title"), p.Title)
+	data["url"] = absURL(c, fmt.Sprintf("/poll/%s", p.ID))
+	renderHTML(h.tmpls, c, http.StatusOK, "poll.html", data)
 }
 
 func (h *PollHandler) SubmitVote(c *gin.Context) {
@@ -174,22 +237,65 @@ func (h *PollHandler) SubmitVote(c *gin.Context) {
 	if !ok {
 		return
 	}
+	if !h.hasAccess(c, p) {
+		respondError(c, http.StatusForbidden, "password required", appPath(c, fmt.Sprintf("/poll/%s", id)))
+		return
+	}
+	if p.Closed() {
+		respondError(c, http.StatusConflict, "poll closed", appPath(c, fmt.Sprintf("/poll/%s", id)))
+		return
+	}
 
 	name := strings.TrimSpace(c.PostForm("name"))
-	if name == "" {
-		respondError(c, http.StatusBadRequest, "name required", fmt.Sprintf("/poll/%s", id))
+	if name == "" && p.NameVisibility != poll.NamesHidden {
+		respondError(c, http.StatusBadRequest, "name required", appPath(c, fmt.Sprintf("/poll/%s", id)))
 		return
 	}
 
 	responses := parseVoteResponses(p.Options, c)
 
-	if err := h.svc.AddVote(id, name, responses); err != nil {
-		slog.Error("add vote error", "err", err)
+	name, err := h.addVote(c, p, name, responses)
+	if err != nil {
+		LoggerFromCtx(c).Error("add vote error", "err", err)
 		c.String(http.StatusInternalServerError, loc.T("error.generic"))
 		return
 	}
+	h.markVoted(c, p, name)
 
-	h.respondAfterMutation(c, func() (*poll.Poll, error) { return h.svc.Get(id) }, false, "poll.html", fmt.Sprintf("/poll/%s", id))
+	h.respondAfterMutation(c, func() (*poll.Poll, error) { return h.svc.Get(c.Request.Context(), id) }, false, "poll.html", appPath(c, fmt.Sprintf("/poll/%s", id)))
+}
+
+// RejectVote answers a vote that the spam guard rejected: AJAX requests get
+// the error as plain text, form posts the poll page with the error.
+func (h *PollHandler) RejectVote(c *gin.Context) {
+	loc := LocalizerFromCtx(c)
+	if isAJAX(c) {
+		c.String(http.StatusBadRequest, loc.T("error.spam_rejected"))
+		return
+	}
+	p, ok := h.mustLoadPoll(c, c.Param("id"), false)
+	if !ok {
+		return
+	}
+	if !h.hasAccess(c, p) {
+		h.renderUnlock(c, p, false)
+		return
+	}
+	data := h.voteTableData(c, p, false)
+	data["title"] = fmt.Sprintf(loc.T("poll.page_title"), p.Title)
+	data["url"] = absURL(c, fmt.Sprintf("/poll/%s", p.ID))
+	data["errors"] = []string{loc.T("error.spam_rejected")}
+	c.Header("Cache-Control", "no-store")
+	renderHTML(h.tmpls, c, http.StatusBadRequest, "poll.html", data)
+}
+
+// addVote stores a vote on p, dropping the name if the poll does not keep
+// voter names. It returns the name the vote is stored under.
+func (h *PollHandler) addVote(c *gin.Context, p *poll.Poll, name string, responses map[string]string) (string, error) {
+	if p.NameVisibility == poll.NamesHidden {
+		return h.svc.AddAnonymousVote(c.Request.Context(), p.ID, responses)
+	}
+	return name, h.svc.AddVote(c.Request.Context(), p.ID, name, responses)
 }
 
 func (h *PollHandler) ShowAdmin(c *gin.Context) {
@@ -201,24 +307,30 @@ func (h *PollHandler) ShowAdmin(c *gin.Context) {
 		return
 	}
 
-	scheme := "http"
-	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
-		scheme = "https"
+	var notice string
+	switch {
+	case c.Query("created") == "1":
+		notice = loc.T("admin.created_notice")
+	case c.Query("rotated") == "1":
+		notice = loc.T("admin.rotated_notice")
 	}
-	baseURL := fmt.Sprintf("%s://%s", scheme, c.Request.Host)
 
-	totals := poll.Totals(p)
-	renderHTML(h.tmpls, c, http.StatusOK, "admin.html", gin.H{
-		"title":        fmt.Sprintf(loc.T("admin.page_title"), p.Title),
-		"poll":         p,
-		"totals":       totals,
-		"winners":      view.WinningOptions(totals),
-		"pollURL":      fmt.Sprintf("%s/poll/%s", baseURL, p.ID),
-		"adminURL":     fmt.Sprintf("%s/poll/%s/admin", baseURL, p.AdminID),
-		"isAdmin":      true,
-		"answerMode":   p.AnswerMode,
-		"headerGroups": view.BuildDateHeaders(p.Options, loc.T),
-	})
+	data := h.voteTableData(c, p, true)
+	data["title"] = fmt.Sprintf(loc.T("admin.page_title"), p.Title)
+	data["pollURL"] = absURL(c, fmt.Sprintf("/poll/%s", p.ID))
+	data["adminURL"] = absURL(c, fmt.Sprintf("/poll/%s/admin", p.AdminID))
+	data["notice"] = notice
+	visibility := p.ResultsVisibility
+	if visibility == "" {
+		visibility = poll.ResultsAlways
+	}
+	data["resultsVisibility"] = loc.T("results." + visibility)
+	names := p.NameVisibility
+	if names == "" {
+		names = poll.NamesPublic
+	}
+	data["nameVisibility"] = loc.T("names." + names)
+	renderHTML(h.tmpls, c, http.StatusOK, "admin.html", data)
 }
 
 func (h *PollHandler) SubmitAdminVote(c *gin.Context) {
@@ -231,20 +343,20 @@ func (h *PollHandler) SubmitAdminVote(c *gin.Context) {
 	}
 
 	name := strings.TrimSpace(c.PostForm("name"))
-	if name == "" {
-		respondError(c, http.StatusBadRequest, "name required", fmt.Sprintf("/poll/%s/admin", adminID))
+	if name == "" && p.NameVisibility != poll.NamesHidden {
+		respondError(c, http.StatusBadRequest, "name required", appPath(c, fmt.Sprintf("/poll/%s/admin", adminID)))
 		return
 	}
 
 	responses := parseVoteResponses(p.Options, c)
 
-	if err := h.svc.AddVote(p.ID, name, responses); err != nil {
-		slog.Error("add vote error", "err", err)
+	if _, err := h.addVote(c, p, name, responses); err != nil {
+		LoggerFromCtx(c).Error("add vote error", "err", err)
 		c.String(http.StatusInternalServerError, loc.T("error.generic"))
 		return
 	}
 
-	h.respondAfterMutation(c, func() (*poll.Poll, error) { return h.svc.GetByAdminID(adminID) }, true, "admin.html", fmt.Sprintf("/poll/%s/admin", adminID))
+	h.respondAfterMutation(c, func() (*poll.Poll, error) { return h.svc.GetByAdminID(c.Request.Context(), adminID) }, true, "admin.html", appPath(c, fmt.Sprintf("/poll/%s/admin", adminID)))
 }
 
 func (h *PollHandler) RemoveVote(c *gin.Context) {
@@ -257,15 +369,15 @@ func (h *PollHandler) RemoveVote(c *gin.Context) {
 
 	voterName := strings.TrimSpace(c.PostForm("voter_name"))
 	if voterName == "" {
-		respondError(c, http.StatusBadRequest, "voter_name required", fmt.Sprintf("/poll/%s/admin", adminID))
+		respondError(c, http.StatusBadRequest, "voter_name required", appPath(c, fmt.Sprintf("/poll/%s/admin", adminID)))
 		return
 	}
 
-	if err := h.svc.RemoveVote(p.ID, voterName); err != nil {
-		slog.Error("remove vote error", "err", err)
+	if err := h.svc.RemoveVote(c.Request.Context(), p.ID, voterName); err != nil {
+		LoggerFromCtx(c).Error("remove vote error", "err", err)
 	}
 
-	h.respondAfterMutation(c, func() (*poll.Poll, error) { return h.svc.GetByAdminID(adminID) }, true, "admin.html", fmt.Sprintf("/poll/%s/admin", adminID))
+	h.respondAfterMutation(c, func() (*poll.Poll, error) { return h.svc.GetByAdminID(c.Request.Context(), adminID) }, true, "admin.html", appPath(c, fmt.Sprintf("/poll/%s/admin", adminID)))
 }
 
 func (h *PollHandler) DeletePoll(c *gin.Context) {
@@ -277,13 +389,75 @@ func (h *PollHandler) DeletePoll(c *gin.Context) {
 		return
 	}
 
-	if err := h.svc.Delete(p.ID); err != nil {
-		slog.Error("delete poll error", "err", err)
+	if err := h.svc.Delete(c.Request.Context(), p.ID); err != nil {
+		LoggerFromCtx(c).Error("delete poll error", "err", err)
+		c.String(http.StatusInternalServerError, loc.T("error.generic"))
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, appPath(c, "/"))
+}
+
+// RotateAdminLink replaces the poll's admin ID, so that a leaked admin link
+// stops working at once, and sends the organizer to the new one.
+func (h *PollHandler) RotateAdminLink(c *gin.Context) {
+	loc := LocalizerFromCtx(c)
+	adminID := c.Param("id")
+
+	p, ok := h.mustLoadPoll(c, adminID, true)
+	if !ok {
+		return
+	}
+
+	newAdminID, err := h.svc.ResetAdminID(c.Request.Context(), p.ID)
+	if err != nil {
+		LoggerFromCtx(c).Error("rotate admin link error", "err", err)
+		c.String(http.StatusInternalServerError, loc.T("error.generic"))
+		return
+	}
+	LoggerFromCtx(c).Info("admin link rotated", "poll", p.ID)
+
+	c.Redirect(http.StatusSeeOther, appPath(c, fmt.Sprintf("/poll/%s/admin?rotated=1", newAdminID)))
+}
+
+// ClosePoll closes the poll to participant votes, or reopens it when the
+// form sets reopen=1.
+func (h *PollHandler) ClosePoll(c *gin.Context) {
+	loc := LocalizerFromCtx(c)
+	adminID := c.Param("id")
+
+	p, ok := h.mustLoadPoll(c, adminID, true)
+	if !ok {
+		return
+	}
+
+	closed := c.PostForm("reopen") != "1"
+	if err := h.svc.SetClosed(c.Request.Context(), p.ID, closed); err != nil {
+		LoggerFromCtx(c).Error("close poll error", "err", err)
 		c.String(http.StatusInternalServerError, loc.T("error.generic"))
 		return
 	}
 
-	c.Redirect(http.StatusSeeOther, "/")
+	c.Redirect(http.StatusSeeOther, appPath(c, fmt.Sprintf("/poll/%s/admin", adminID)))
+}
+
+// ExportPoll downloads the poll as a single-poll archive that can be loaded
+// into another instance with `meetkat import`.
+func (h *PollHandler) ExportPoll(c *gin.Context) {
+	adminID := c.Param("id")
+
+	p, ok := h.mustLoadPoll(c, adminID, true)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="meetkat-%s.json"`, p.ID))
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	if err := archive.Write(c.Writer, archive.New([]*poll.Poll{p}, time.Now())); err != nil {
+		LoggerFromCtx(c).Error("export poll error", "err", err)
+	}
 }
 
 func (h *PollHandler) UpdateVote(c *gin.Context) {
@@ -298,17 +472,17 @@ func (h *PollHandler) UpdateVote(c *gin.Context) {
 	newName := strings.TrimSpace(c.PostForm("name"))
 
 	if newName == "" {
-		respondError(c, http.StatusBadRequest, "name required", fmt.Sprintf("/poll/%s/admin", adminID))
+		respondError(c, http.StatusBadRequest, "name required", appPath(c, fmt.Sprintf("/poll/%s/admin", adminID)))
 		return
 	}
 
 	responses := parseVoteResponses(p.Options, c)
 
-	if err := h.svc.UpdateVote(p.ID, oldName, newName, responses); err != nil {
-		slog.Error("update vote error", "err", err)
+	if err := h.svc.UpdateVote(c.Request.Context(), p.ID, oldName, newName, responses); err != nil {
+		LoggerFromCtx(c).Error("update vote error", "err", err)
 	}
 
-	h.respondAfterMutation(c, func() (*poll.Poll, error) { return h.svc.GetByAdminID(adminID) }, true, "admin.html", fmt.Sprintf("/poll/%s/admin", adminID))
+	h.respondAfterMutation(c, func() (*poll.Poll, error) { return h.svc.GetByAdminID(c.Request.Context(), adminID) }, true, "admin.html", appPath(c, fmt.Sprintf("/poll/%s/admin", adminID)))
 }
 
 // parseVoteResponses reads vote-<option> form values and returns a response map.
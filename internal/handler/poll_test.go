@@ -1,18 +1,25 @@
 package handler
 
 import (
+	"context"
+	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"meetkat/internal/archive"
 	"meetkat/internal/i18n"
+	"meetkat/internal/metrics"
 	"meetkat/internal/middleware"
 	"meetkat/internal/poll"
 	"meetkat/internal/view"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func init() {
@@ -29,7 +36,7 @@ func setupLangTestRouter() *gin.Engine {
 	h := NewPollHandler(svc, tmpls)
 
 	r := gin.New()
-	r.Use(middleware.LangCookie(tr))
+	r.Use(middleware.LangCookie(tr, middleware.DefaultCookieConfig()))
 	r.GET("/new", h.ShowNew)
 	return r
 }
@@ -44,16 +51,44 @@ func setupTestRouter() (*gin.Engine, *poll.Service) {
 	h := NewPollHandler(svc, tmpls)
 
 	r := gin.New()
-	r.Use(middleware.LangCookie(tr))
+	r.Use(middleware.LangCookie(tr, middleware.DefaultCookieConfig()))
 	r.GET("/new", h.ShowNew)
 	r.POST("/new", h.CreatePoll)
 	r.GET("/poll/:id", h.ShowPoll)
 	r.POST("/poll/:id/vote", h.SubmitVote)
+	r.POST("/poll/:id/unlock", h.UnlockPoll)
 	r.GET("/poll/:id/admin", h.ShowAdmin)
 	r.POST("/poll/:id/admin/remove", h.RemoveVote)
 	r.POST("/poll/:id/admin/vote", h.SubmitAdminVote)
 	r.POST("/poll/:id/admin/delete", h.DeletePoll)
 	r.POST("/poll/:id/admin/edit", h.UpdateVote)
+	r.POST("/poll/:id/admin/rotate", h.RotateAdminLink)
+	r.POST("/poll/:id/admin/close", h.ClosePoll)
+	r.GET("/poll/:id/admin/export", h.ExportPoll)
+	return r, svc
+}
+
+// setupBasePathRouter serves the poll routes under /meetkat like main does
+// for a configured base path.
+func setupBasePathRouter() (*gin.Engine, *poll.Service) {
+	tr, err := i18n.New()
+	if err != nil {
+		panic(err)
+	}
+	svc := poll.NewService(poll.NewMemoryRepository())
+	tmpls := view.LoadTemplates("../..")
+	h := NewPollHandler(svc, tmpls)
+
+	r := gin.New()
+	r.Use(middleware.LangCookie(tr, middleware.DefaultCookieConfig()))
+	r.Use(middleware.BasePath("/meetkat"))
+	app := r.Group("/meetkat/")
+	app.GET("/", NewHomeHandler(tmpls).ShowHome)
+	app.GET("/new", h.ShowNew)
+	app.POST("/new", h.CreatePoll)
+	app.GET("/poll/:id", h.ShowPoll)
+	app.GET("/poll/:id/admin", h.ShowAdmin)
+	app.POST("/poll/:id/admin/delete", h.DeletePoll)
 	return r, svc
 }
 
@@ -67,7 +102,7 @@ func postForm(router http.Handler, path string, form url.Values) *httptest.Respo
 
 // seedPoll creates a yn poll directly via the service for testing.
 func seedPoll(svc *poll.Service, title string, options []string) *poll.Poll {
-	p, err := svc.Create(title, "", "yn", options)
+	p, err := svc.Create(context.Background(), title, "", "yn", options, poll.Settings{})
 	if err != nil {
 		panic(err)
 	}
@@ -76,7 +111,7 @@ func seedPoll(svc *poll.Service, title string, options []string) *poll.Poll {
 
 // seedPollYMN creates a ymn poll directly via the service for testing.
 func seedPollYMN(svc *poll.Service, title string, options []string) *poll.Poll {
-	p, err := svc.Create(title, "", "ymn", options)
+	p, err := svc.Create(context.Background(), title, "", "ymn", options, poll.Settings{})
 	if err != nil {
 		panic(err)
 	}
@@ -100,7 +135,7 @@ func TestVoteSubmission(t *testing.T) {
 		t.Fatalf("expected redirect to /poll/%s, got %q", p.ID, loc)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote, got %d", len(got.Votes))
 	}
@@ -130,7 +165,7 @@ func TestVoteEmptyName(t *testing.T) {
 		t.Fatalf("expected 303, got %d", w.Code)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 0 {
 		t.Error("vote should not have been saved")
 	}
@@ -149,7 +184,7 @@ func TestVoteWhitespaceOnlyName(t *testing.T) {
 		t.Fatalf("expected 303, got %d", w.Code)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 0 {
 		t.Error("vote should not have been saved")
 	}
@@ -182,7 +217,7 @@ func TestVoteResponseValues(t *testing.T) {
 		t.Fatalf("expected 303, got %d", w.Code)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	v := got.Votes[0]
 	tests := []struct {
 		option string
@@ -213,7 +248,7 @@ func TestMultipleVotes(t *testing.T) {
 		"vote-2025-09-01": {"yes"},
 	})
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 2 {
 		t.Fatalf("expected 2 votes, got %d", len(got.Votes))
 	}
@@ -229,8 +264,8 @@ func TestPollViewWithVotes(t *testing.T) {
 	router, svc := setupTestRouter()
 	p := seedPoll(svc, "Team dinner", []string{"2025-10-01", "2025-10-02"})
 
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"2025-10-01": "yes", "2025-10-02": "no"})
-	_ = svc.AddVote(p.ID, "Bob", map[string]string{"2025-10-01": "yes", "2025-10-02": "yes"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"2025-10-01": "yes", "2025-10-02": "no"})
+	_ = svc.AddVote(context.Background(), p.ID, "Bob", map[string]string{"2025-10-01": "yes", "2025-10-02": "yes"})
 
 	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.ID, nil)
 	w := httptest.NewRecorder()
@@ -262,6 +297,7 @@ func TestPollViewNotFound(t *testing.T) {
 
 func TestCreatePollRedirectsToAdmin(t *testing.T) {
 	router, svc := setupTestRouter()
+	created := testutil.ToFloat64(metrics.PollsCreated)
 
 	form := url.Values{
 		"title":   {"Team dinner"},
@@ -272,9 +308,12 @@ func TestCreatePollRedirectsToAdmin(t *testing.T) {
 	if w.Code != http.StatusSeeOther {
 		t.Fatalf("expected 303, got %d", w.Code)
 	}
+	if got := testutil.ToFloat64(metrics.PollsCreated) - created; got != 1 {
+		t.Errorf("polls created = %v, want 1", got)
+	}
 
 	loc := w.Header().Get("Location")
-	if !strings.HasSuffix(loc, "/admin") {
+	if !strings.HasSuffix(loc, "/admin?created=1") {
 		t.Fatalf("expected redirect to admin page, got %q", loc)
 	}
 
@@ -286,7 +325,7 @@ func TestCreatePollRedirectsToAdmin(t *testing.T) {
 	}
 	adminID := parts[2]
 
-	p, err := svc.GetByAdminID(adminID)
+	p, err := svc.GetByAdminID(context.Background(), adminID)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -298,10 +337,27 @@ func TestCreatePollRedirectsToAdmin(t *testing.T) {
 	}
 }
 
+func TestShowAdminCreatedNotice(t *testing.T) {
+	router, svc := setupTestRouter()
+	p := seedPoll(svc, "Admin poll", []string{"Mon"})
+
+	for path, want := range map[string]bool{
+		"/poll/" + p.AdminID + "/admin?created=1": true,
+		"/poll/" + p.AdminID + "/admin":           false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if got := strings.Contains(w.Body.String(), `role="status"`); got != want {
+			t.Errorf("%s: created notice shown = %v, want %v", path, got, want)
+		}
+	}
+}
+
 func TestShowAdmin(t *testing.T) {
 	router, svc := setupTestRouter()
 	p := seedPoll(svc, "Admin poll", []string{"Mon", "Tue"})
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
 
 	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.AdminID+"/admin", nil)
 	w := httptest.NewRecorder()
@@ -326,6 +382,124 @@ func TestShowAdmin(t *testing.T) {
 	}
 }
 
+func TestShowAdminIgnoresSpoofedForwardedProto(t *testing.T) {
+	router, svc := setupTestRouter()
+	p := seedPoll(svc, "Admin poll", []string{"Mon"})
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.AdminID+"/admin", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if strings.Contains(body, "https://example.com/poll/") {
+		t.Error("untrusted X-Forwarded-Proto changed the share link scheme")
+	}
+	if !strings.Contains(body, "http://example.com/poll/"+p.ID) {
+		t.Error("expected http share link")
+	}
+}
+
+func TestBasePathURLs(t *testing.T) {
+	router, svc := setupBasePathRouter()
+
+	w := postForm(router, "/meetkat/new", url.Values{"title": {"Offsite"}, "dates[]": {"2025-06-10"}})
+	loc := w.Header().Get("Location")
+	if !strings.HasPrefix(loc, "/meetkat/poll/") {
+		t.Fatalf("expected redirect under base path, got %q", loc)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, loc, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	adminID := strings.Split(strings.TrimPrefix(loc, "/meetkat/poll/"), "/")[0]
+	p, _ := svc.GetByAdminID(context.Background(), adminID)
+	for _, want := range []string{
+		`href="/meetkat/static/css/style.css"`,
+		`action="/meetkat/poll/` + p.AdminID + `/admin/delete"`,
+		"http://example.com/meetkat/poll/" + p.ID,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("admin page does not contain %s", want)
+		}
+	}
+	if strings.Contains(body, `href="/static/`) {
+		t.Error("admin page links to static assets outside the base path")
+	}
+
+	w = postForm(router, "/meetkat/poll/"+p.AdminID+"/admin/delete", url.Values{})
+	if got := w.Header().Get("Location"); got != "/meetkat/" {
+		t.Errorf("delete redirect = %q, want /meetkat/", got)
+	}
+}
+
+func TestBasePathStaticURLs(t *testing.T) {
+	router, svc := setupBasePathRouter()
+	p := seedPollYMN(svc, "Offsite", []string{"2025-06-10"})
+	if err := svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"2025-06-10": "yes"}); err != nil {
+		t.Fatal(err)
+	}
+	rootStatic := regexp.MustCompile(`["'(]/static/`)
+
+	for _, path := range []string{"/meetkat/", "/meetkat/new", "/meetkat/poll/" + p.ID, "/meetkat/poll/" + p.AdminID + "/admin", "/meetkat/poll/nonexistent"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		body := w.Body.String()
+		if !strings.Contains(body, "/meetkat/static/") {
+			t.Errorf("%s: no static assets under the base path", path)
+		}
+		if m := rootStatic.FindString(body); m != "" {
+			t.Errorf("%s: refers to static assets outside the base path: %s", path, m)
+		}
+	}
+}
+
+func TestBaseURLOverridesHostHeader(t *testing.T) {
+	router, svc := setupTestRouter()
+	// Routes registered after Use get the middleware; the others keep the
+	// Host fallback.
+	h := NewPollHandler(svc, view.LoadTemplates("../.."))
+	router.Use(middleware.BaseURL("https://meet.example.org"))
+	router.GET("/canonical/poll/:id", h.ShowPoll)
+	router.GET("/canonical/poll/:id/admin", h.ShowAdmin)
+	p := seedPoll(svc, "Canonical", []string{"Mon"})
+
+	for path, want := range map[string]string{
+		"/canonical/poll/" + p.ID:                 `value="https://meet.example.org/poll/` + p.ID + `"`,
+		"/canonical/poll/" + p.AdminID + "/admin": `value="https://meet.example.org/poll/` + p.AdminID + `/admin"`,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = "evil.example"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		body := w.Body.String()
+		if !strings.Contains(body, want) {
+			t.Errorf("%s: expected %s", path, want)
+		}
+		if strings.Contains(body, "evil.example") {
+			t.Errorf("%s: Host header leaked into links", path)
+		}
+	}
+}
+
+func TestShareURLFallsBackToHost(t *testing.T) {
+	router, svc := setupTestRouter()
+	p := seedPoll(svc, "Fallback", []string{"Mon"})
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.ID, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if want := `value="http://example.com/poll/` + p.ID + `"`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected share link %s", want)
+	}
+}
+
 func TestShowAdminNotFound(t *testing.T) {
 	router, _ := setupTestRouter()
 
@@ -341,8 +515,8 @@ func TestShowAdminNotFound(t *testing.T) {
 func TestRemoveVoteHandler(t *testing.T) {
 	router, svc := setupTestRouter()
 	p := seedPoll(svc, "Remove test", []string{"Mon"})
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes"})
-	_ = svc.AddVote(p.ID, "Bob", map[string]string{"Mon": "yes"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes"})
+	_ = svc.AddVote(context.Background(), p.ID, "Bob", map[string]string{"Mon": "yes"})
 
 	form := url.Values{
 		"voter_name": {"Alice"},
@@ -357,7 +531,7 @@ func TestRemoveVoteHandler(t *testing.T) {
 		t.Fatalf("expected redirect to %q, got %q", expectedLoc, loc)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote after removal, got %d", len(got.Votes))
 	}
@@ -369,7 +543,7 @@ func TestRemoveVoteHandler(t *testing.T) {
 func TestDeletePollHandler(t *testing.T) {
 	router, svc := setupTestRouter()
 	p := seedPoll(svc, "Delete me", []string{"Mon"})
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes"})
 
 	form := url.Values{}
 	w := postForm(router, "/poll/"+p.AdminID+"/admin/delete", form)
@@ -381,7 +555,7 @@ func TestDeletePollHandler(t *testing.T) {
 		t.Fatalf("expected redirect to /, got %q", loc)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if got != nil {
 		t.Error("expected poll to be deleted")
 	}
@@ -398,10 +572,329 @@ func TestDeletePollNotFound(t *testing.T) {
 	}
 }
 
+func TestRotateAdminLink(t *testing.T) {
+	router, svc := setupTestRouter()
+	p := seedPoll(svc, "Leaked", []string{"Mon"})
+
+	w := postForm(router, "/poll/"+p.AdminID+"/admin/rotate", url.Values{})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d", w.Code)
+	}
+	loc := w.Header().Get("Location")
+	newAdminID := strings.TrimSuffix(strings.TrimPrefix(loc, "/poll/"), "/admin?rotated=1")
+	if newAdminID == loc || newAdminID == p.AdminID {
+		t.Fatalf("expected redirect to a new admin link, got %q", loc)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.AdminID+"/admin", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("old admin link: expected 404, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, loc, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("new admin link: expected 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `role="status"`) {
+		t.Error("expected the new admin page to say the link was replaced")
+	}
+	if got, _ := svc.Get(context.Background(), p.ID); got == nil {
+		t.Error("expected the poll to be kept")
+	}
+}
+
+func TestRotateAdminLinkNotFound(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := postForm(router, "/poll/nonexistent/admin/rotate", url.Values{})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestPasswordProtectedPoll(t *testing.T) {
+	router, svc := setupTestRouter()
+	p, err := svc.Create(context.Background(), "Secret", "", "yn", []string{"Mon"}, poll.Settings{Password: "hunter2"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	get := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/poll/"+p.ID, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 before unlocking, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "Secret") {
+		t.Error("expected the unlock page not to show the poll")
+	}
+	if !strings.Contains(w.Body.String(), `action="/poll/`+p.ID+`/unlock"`) {
+		t.Error("expected the password prompt")
+	}
+
+	postForm(router, "/poll/"+p.ID+"/vote", url.Values{"name": {"Eve"}, "vote-Mon": {"yes"}})
+	if got, _ := svc.Get(context.Background(), p.ID); len(got.Votes) != 0 {
+		t.Errorf("vote without password: expected no votes, got %d", len(got.Votes))
+	}
+
+	w = postForm(router, "/poll/"+p.ID+"/unlock", url.Values{"password": {"wrong"}})
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `role="alert"`) {
+		t.Errorf("wrong password: expected 403 with an error, got %d", w.Code)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("wrong password: expected no cookie")
+	}
+
+	w = postForm(router, "/poll/"+p.ID+"/unlock", url.Values{"password": {"hunter2"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("correct password: expected 303, got %d", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("expected one HttpOnly access cookie, got %+v", cookies)
+	}
+	access := cookies[0]
+
+	w = get(access)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Secret") {
+		t.Errorf("with access cookie: expected the poll, got %d", w.Code)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Error("expected protected polls not to be cached")
+	}
+
+	tampered := *access
+	tampered.Value = "99999999999" + access.Value[strings.Index(access.Value, "."):]
+	if w := get(&tampered); w.Code != http.StatusForbidden {
+		t.Errorf("tampered cookie: expected 403, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/poll/"+p.ID+"/vote",
+		strings.NewReader(url.Values{"name": {"Alice"}, "vote-Mon": {"yes"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(access)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("vote with access cookie: expected 303, got %d", w.Code)
+	}
+}
+
+func TestCreatePollWithPassword(t *testing.T) {
+	router, svc := setupTestRouter()
+
+	w := postForm(router, "/new", url.Values{
+		"title":    {"Secret"},
+		"dates[]":  {"2025-06-01"},
+		"password": {strings.Repeat("x", poll.MaxPasswordLen+1)},
+	})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("too long password: expected 422, got %d", w.Code)
+	}
+
+	w = postForm(router, "/new", url.Values{
+		"title":    {"Secret"},
+		"dates[]":  {"2025-06-01"},
+		"password": {"hunter2"},
+	})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d", w.Code)
+	}
+	adminID := strings.TrimSuffix(strings.TrimPrefix(w.Header().Get("Location"), "/poll/"), "/admin?created=1")
+	p, _ := svc.GetByAdminID(context.Background(), adminID)
+	if p == nil || !poll.CheckPassword(p, "hunter2") {
+		t.Fatalf("expected a password-protected poll, got %+v", p)
+	}
+}
+
+func TestResultsHiddenUntilVote(t *testing.T) {
+	router, svc := setupTestRouter()
+	p, _ := svc.Create(context.Background(), "Blind", "", "yn", []string{"Mon"}, poll.Settings{ResultsVisibility: poll.ResultsAfterVote})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes"})
+
+	get := func(cookies ...*http.Cookie) string {
+		req := httptest.NewRequest(http.MethodGet, "/poll/"+p.ID, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		return w.Body.String()
+	}
+
+	body := get()
+	if strings.Contains(body, "Alice") {
+		t.Error("expected other voters to be hidden before voting")
+	}
+	if !strings.Contains(body, "once you have voted") {
+		t.Error("expected a note on when results are shown")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/poll/"+p.ID+"/vote",
+		strings.NewReader(url.Values{"name": {"Bob"}, "vote-Mon": {"no"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Requested-With", "fetch")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("vote: expected 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Alice") {
+		t.Error("expected the vote table fragment to show results after voting")
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected a voted cookie, got %+v", cookies)
+	}
+
+	if body := get(cookies[0]); !strings.Contains(body, "Alice") {
+		t.Error("expected results with the voted cookie")
+	}
+	forged := *cookies[0]
+	forged.Value = "forged"
+	if body := get(&forged); strings.Contains(body, "Alice") {
+		t.Error("expected a forged voted cookie to be ignored")
+	}
+
+	// The cookie stands for Bob's vote and ends with it.
+	if err := svc.RemoveVote(context.Background(), p.ID, "Bob"); err != nil {
+		t.Fatal(err)
+	}
+	if body := get(cookies[0]); strings.Contains(body, "Alice") {
+		t.Error("expected the voted cookie to end with the removed vote")
+	}
+}
+
+func TestResultsAdminOnly(t *testing.T) {
+	router, svc := setupTestRouter()
+	p, _ := svc.Create(context.Background(), "Private", "", "yn", []string{"Mon"}, poll.Settings{ResultsVisibility: poll.ResultsAdminOnly})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes"})
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.ID, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), "Alice") {
+		t.Error("expected voters to be hidden from participants")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/poll/"+p.AdminID+"/admin", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "Alice") {
+		t.Error("expected the admin to see voters")
+	}
+}
+
+func TestAnonymousPollShowsOnlyTotals(t *testing.T) {
+	router, svc := setupTestRouter()
+	p, _ := svc.Create(context.Background(), "Sensitive", "", "yn", []string{"Mon"}, poll.Settings{NameVisibility: poll.NamesAdminOnly})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes"})
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.ID, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	body := w.Body.String()
+	if strings.Contains(body, "Alice") {
+		t.Error("expected voter names to be hidden from participants")
+	}
+	if !strings.Contains(body, "Only the totals are shown") || !strings.Contains(body, "<tfoot>") {
+		t.Error("expected the totals to be shown")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/poll/"+p.AdminID+"/admin", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "Alice") {
+		t.Error("expected the admin to see voter names")
+	}
+}
+
+func TestHiddenNamesAreNotStored(t *testing.T) {
+	router, svc := setupTestRouter()
+	p, _ := svc.Create(context.Background(), "Secret ballot", "", "yn", []string{"Mon"}, poll.Settings{NameVisibility: poll.NamesHidden})
+
+	w := postForm(router, "/poll/"+p.ID+"/vote", url.Values{"name": {"Alice"}, "vote-Mon": {"yes"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("vote: expected 303, got %d", w.Code)
+	}
+	w = postForm(router, "/poll/"+p.ID+"/vote", url.Values{"vote-Mon": {"no"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("vote without name: expected 303, got %d", w.Code)
+	}
+	got, _ := svc.Get(context.Background(), p.ID)
+	if len(got.Votes) != 2 {
+		t.Fatalf("expected 2 votes, got %d", len(got.Votes))
+	}
+	for _, v := range got.Votes {
+		if v.Name == "Alice" {
+			t.Error("expected the voter name not to be stored")
+		}
+	}
+
+	for _, path := range []string{"/poll/" + p.AdminID + "/admin", "/poll/" + p.AdminID + "/admin/export"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if strings.Contains(w.Body.String(), "Alice") {
+			t.Errorf("%s: expected no voter name", path)
+		}
+	}
+}
+
+func TestClosePoll(t *testing.T) {
+	router, svc := setupTestRouter()
+	p := seedPoll(svc, "Closing", []string{"Mon"})
+
+	w := postForm(router, "/poll/"+p.AdminID+"/admin/close", url.Values{})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("close: expected 303, got %d", w.Code)
+	}
+	if got, _ := svc.Get(context.Background(), p.ID); !got.Closed() {
+		t.Fatal("expected the poll to be closed")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), `id="vote-submit"`) {
+		t.Error("expected no vote button on a closed poll")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/poll/"+p.ID+"/vote",
+		strings.NewReader(url.Values{"name": {"Late"}, "vote-Mon": {"yes"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Requested-With", "fetch")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("vote on closed poll: expected 409, got %d", w.Code)
+	}
+
+	postForm(router, "/poll/"+p.AdminID+"/admin/close", url.Values{"reopen": {"1"}})
+	if got, _ := svc.Get(context.Background(), p.ID); got.Closed() {
+		t.Error("expected the poll to be reopened")
+	}
+}
+
 func TestUpdateVoteHandler(t *testing.T) {
 	router, svc := setupTestRouter()
 	p := seedPoll(svc, "Edit test", []string{"Mon", "Tue"})
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
 
 	form := url.Values{
 		"old_name": {"Alice"},
@@ -415,7 +908,7 @@ func TestUpdateVoteHandler(t *testing.T) {
 		t.Fatalf("expected 303, got %d", w.Code)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote, got %d", len(got.Votes))
 	}
@@ -434,9 +927,9 @@ func TestUpdateVoteHandler(t *testing.T) {
 func TestUpdateVotePreservesPosition(t *testing.T) {
 	router, svc := setupTestRouter()
 	p := seedPoll(svc, "Position test", []string{"Mon", "Tue"})
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
-	_ = svc.AddVote(p.ID, "Bob", map[string]string{"Mon": "no", "Tue": "yes"})
-	_ = svc.AddVote(p.ID, "Carol", map[string]string{"Mon": "yes", "Tue": "yes"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
+	_ = svc.AddVote(context.Background(), p.ID, "Bob", map[string]string{"Mon": "no", "Tue": "yes"})
+	_ = svc.AddVote(context.Background(), p.ID, "Carol", map[string]string{"Mon": "yes", "Tue": "yes"})
 
 	// Edit Bob (middle vote) — should stay in position 1.
 	form := url.Values{
@@ -450,7 +943,7 @@ func TestUpdateVotePreservesPosition(t *testing.T) {
 		t.Fatalf("expected 303, got %d", w.Code)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 3 {
 		t.Fatalf("expected 3 votes, got %d", len(got.Votes))
 	}
@@ -465,7 +958,7 @@ func TestUpdateVotePreservesPosition(t *testing.T) {
 func TestUpdateVoteEmptyName(t *testing.T) {
 	router, svc := setupTestRouter()
 	p := seedPoll(svc, "Edit empty", []string{"Mon"})
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes"})
 
 	form := url.Values{
 		"old_name": {"Alice"},
@@ -478,7 +971,7 @@ func TestUpdateVoteEmptyName(t *testing.T) {
 		t.Fatalf("expected 303 redirect, got %d", w.Code)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote unchanged, got %d", len(got.Votes))
 	}
@@ -592,7 +1085,7 @@ func TestCreatePollWithAnswerModeYMN(t *testing.T) {
 	parts := strings.Split(loc, "/")
 	adminID := parts[2]
 
-	p, err := svc.GetByAdminID(adminID)
+	p, err := svc.GetByAdminID(context.Background(), adminID)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -618,7 +1111,7 @@ func TestCreatePollDefaultsToYN(t *testing.T) {
 	parts := strings.Split(loc, "/")
 	adminID := parts[2]
 
-	p, _ := svc.GetByAdminID(adminID)
+	p, _ := svc.GetByAdminID(context.Background(), adminID)
 	if p.AnswerMode != "yn" {
 		t.Errorf("expected answer mode yn, got %q", p.AnswerMode)
 	}
@@ -640,7 +1133,7 @@ func TestVoteMaybeOnYMNPoll(t *testing.T) {
 		t.Fatalf("expected 303, got %d", w.Code)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote, got %d", len(got.Votes))
 	}
@@ -672,7 +1165,7 @@ func TestMaybeIgnoredOnYNPoll(t *testing.T) {
 		t.Fatalf("expected 303, got %d", w.Code)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	v := got.Votes[0]
 	// The handler accepts "maybe" as a valid value regardless of answer mode,
 	// since the form wouldn't normally offer it for yn polls.
@@ -705,7 +1198,7 @@ func TestTotalsWithMaybeViaHandler(t *testing.T) {
 		"vote-Tue": {"no"},
 	})
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	totals := poll.Totals(got)
 
 	if totals["Mon"].Yes != 2 {
@@ -725,7 +1218,7 @@ func TestTotalsWithMaybeViaHandler(t *testing.T) {
 func TestEditVoteMaybeOnYMNPoll(t *testing.T) {
 	router, svc := setupTestRouter()
 	p := seedPollYMN(svc, "Edit maybe", []string{"Mon", "Tue"})
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
 
 	form := url.Values{
 		"old_name": {"Alice"},
@@ -739,7 +1232,7 @@ func TestEditVoteMaybeOnYMNPoll(t *testing.T) {
 		t.Fatalf("expected 303, got %d", w.Code)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	v := got.Votes[0]
 	if v.Responses["Mon"] != "maybe" {
 		t.Errorf("Mon: got %q, want maybe", v.Responses["Mon"])
@@ -752,7 +1245,7 @@ func TestEditVoteMaybeOnYMNPoll(t *testing.T) {
 func TestYMNPollViewRendersCorrectly(t *testing.T) {
 	router, svc := setupTestRouter()
 	p := seedPollYMN(svc, "Render test", []string{"Mon", "Tue"})
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "maybe"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "maybe"})
 
 	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.ID, nil)
 	w := httptest.NewRecorder()
@@ -822,3 +1315,128 @@ func TestAdminVoteMaybeOnYMNPoll(t *testing.T) {
 		t.Error("expected admin view to render maybe icon for ymn poll")
 	}
 }
+
+func TestExportPoll(t *testing.T) {
+	router, svc := setupTestRouter()
+	p := seedPoll(svc, "Export me", []string{"2025-06-10"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"2025-06-10": "yes"})
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.AdminID+"/admin/export", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "meetkat-"+p.ID+".json") {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	a, err := archive.Read(w.Body)
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	if len(a.Polls) != 1 || a.Polls[0].ID != p.ID || len(a.Polls[0].Votes) != 1 {
+		t.Errorf("unexpected archive contents: %+v", a.Polls)
+	}
+}
+
+func TestExportPollRequiresAdminID(t *testing.T) {
+	router, svc := setupTestRouter()
+	p := seedPoll(svc, "Private", []string{"2025-06-10"})
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/"+p.ID+"/admin/export", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for public ID, got %d", w.Code)
+	}
+}
+
+func TestSpamRejectionRerendersForm(t *testing.T) {
+	tr, err := i18n.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := poll.NewService(poll.NewMemoryRepository())
+	h := NewPollHandler(svc, view.LoadTemplates("../.."))
+	spam := middleware.NewSpamGuard(middleware.SpamConfig{Enabled: true, Key: []byte("test-key"), MaxAge: time.Hour})
+
+	r := gin.New()
+	r.Use(middleware.LangCookie(tr, middleware.DefaultCookieConfig()))
+	r.POST("/new", spam.Protect(h.RejectNewPoll), h.CreatePoll)
+	r.POST("/poll/:id/vote", spam.Protect(h.RejectVote), h.SubmitVote)
+	p := seedPoll(svc, "Dinner", []string{"2025-06-10"})
+
+	// Without a form token every submission is rejected.
+	w := postForm(r, "/new", url.Values{"title": {"Team lunch"}, "dates[]": {"2025-06-10"}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("new: status = %d, want 400", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "could not be verified") || !strings.Contains(body, `value="Team lunch"`) {
+		t.Error("new: form not re-rendered with the error and the entered title")
+	}
+	if !strings.Contains(body, `name="form_token" value="`+w.Header().Get("X-Form-Token")+`"`) {
+		t.Error("new: re-rendered form lacks the fresh form token")
+	}
+
+	w = postForm(r, "/poll/"+p.ID+"/vote", url.Values{"name": {"Alice"}, "vote-2025-06-10": {"yes"}})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "could not be verified") {
+		t.Errorf("vote: status = %d, error not shown", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/poll/"+p.ID+"/vote", strings.NewReader("name=Alice"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Requested-With", "fetch")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), "<html") {
+		t.Errorf("AJAX vote: status = %d, body = %q", w.Code, w.Body.String())
+	}
+	if got, _ := svc.Get(context.Background(), p.ID); len(got.Votes) != 0 {
+		t.Error("rejected vote was stored")
+	}
+}
+
+func TestVotedCookieBoundToVote(t *testing.T) {
+	router, svc := setupTestRouter()
+	p, _ := svc.Create(context.Background(), "Blind", "", "yn", []string{"Mon"}, poll.Settings{
+		ResultsVisibility: poll.ResultsAfterVote,
+		NameVisibility:    poll.NamesHidden,
+	})
+
+	var values []string
+	for range 2 {
+		w := postForm(router, "/poll/"+p.ID+"/vote", url.Values{"vote-Mon": {"yes"}})
+		cookies := w.Result().Cookies()
+		if w.Code != http.StatusSeeOther || len(cookies) != 1 {
+			t.Fatalf("vote: status = %d, cookies = %+v", w.Code, cookies)
+		}
+		values = append(values, cookies[0].Value)
+	}
+	if values[0] == values[1] {
+		t.Error("two voters got the same voted cookie")
+	}
+
+	hidden := func(value string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/poll/"+p.ID, nil)
+		req.AddCookie(&http.Cookie{Name: votedCookieName(p.ID), Value: value})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return strings.Contains(w.Body.String(), "once you have voted")
+	}
+	if hidden(values[0]) || hidden(values[1]) {
+		t.Fatal("expected both voters to see the results")
+	}
+
+	// Removing the first vote ends only the first voter's cookie.
+	encoded, _, _ := strings.Cut(values[0], ".")
+	name, _ := base64.RawURLEncoding.DecodeString(encoded)
+	if err := svc.RemoveVote(context.Background(), p.ID, string(name)); err != nil {
+		t.Fatal(err)
+	}
+	if !hidden(values[0]) || hidden(values[1]) {
+		t.Error("expected only the removed voter to lose the results")
+	}
+}
@@ -0,0 +1,112 @@
+package listen
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+// LocalIP stands in for the peer address of Unix socket connections, which
+// have none. Gin derives the client IP from it, so a reverse proxy on the
+// socket can be trusted with trusted_proxies = ["127.0.0.1"] and its
+// X-Forwarded-For header used for rate limiting. Without that, every client
+// on the socket has this one IP.
+var LocalIP = netip.AddrFrom4([4]byte{127, 0, 0, 1})
+
+var localAddr = net.TCPAddrFromAddrPort(netip.AddrPortFrom(LocalIP, 0))
+
+// Unix listens on a Unix domain socket at path with the given file mode. A
+// stale socket left behind by a previous run is removed first; any other
+// file at path is an error.
+func Unix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("chmod socket: %w", err)
+	}
+	return &localListener{l}, nil
+}
+
+// Systemd returns the sockets passed by systemd socket activation, or none
+// when the process was not socket-activated. The LISTEN_* variables are
+// cleared so that child processes do not pick the sockets up again.
+func Systemd() ([]net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, errors.New("LISTEN_FDS is not a positive number")
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(listenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		l, err := net.FileListener(f)
+		// FileListener duplicates the descriptor.
+		_ = f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("socket %s: %w", name, err)
+		}
+		if l.Addr().Network() == "unix" {
+			l = &localListener{l}
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// localListener reports connections on a Unix socket as coming from
+// localAddr.
+type localListener struct {
+	net.Listener
+}
+
+func (l *localListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &localConn{c}, nil
+}
+
+type localConn struct {
+	net.Conn
+}
+
+func (c *localConn) RemoteAddr() net.Addr {
+	return localAddr
+}
@@ -0,0 +1,102 @@
+package listen
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestUnixSocketModeAndLocalPeer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meetkat.sock")
+	l, err := Unix(path, 0o660)
+	if err != nil {
+		t.Fatalf("Unix: %v", err)
+	}
+	defer func() { _ = l.Close() }()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0o660 {
+		t.Errorf("socket mode = %o, want 660", got)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.RemoteAddr)
+	})}
+	go func() { _ = srv.Serve(l) }()
+	defer func() { _ = srv.Close() }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if host, _, err := net.SplitHostPort(string(body)); err != nil || host != "127.0.0.1" {
+		t.Errorf("RemoteAddr = %q, want 127.0.0.1", body)
+	}
+}
+
+func TestUnixReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meetkat.sock")
+	// A socket file left behind by a process that did not clean up.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	l, err := Unix(path, 0o600)
+	if err != nil {
+		t.Fatalf("Unix over stale socket: %v", err)
+	}
+	_ = l.Close()
+}
+
+func TestUnixRefusesRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meetkat.db")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Unix(path, 0o600); err == nil {
+		t.Fatal("expected error for a regular file at the socket path")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("regular file was removed: %v", err)
+	}
+}
+
+func TestSystemdWithoutActivation(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+	listeners, err := Systemd()
+	if err != nil || len(listeners) != 0 {
+		t.Fatalf("Systemd() = %v, %v; want no listeners", listeners, err)
+	}
+}
+
+func TestSystemdIgnoresOtherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	listeners, err := Systemd()
+	if err != nil || len(listeners) != 0 {
+		t.Fatalf("Systemd() = %v, %v; want no listeners", listeners, err)
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("LISTEN_FDS was not cleared")
+	}
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every meetkat metric plus the Go runtime and process
+// collectors. It is separate from the prometheus default registry so that
+// libraries cannot add metrics behind our back.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "meetkat_http_requests_total",
+		Help: "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "meetkat_http_request_duration_seconds",
+		Help:    "HTTP request latency by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "meetkat_ratelimit_rejections_total",
+		Help: "Requests rejected by a rate limiter, by route.",
+	}, []string{"route"})
+
+	CSRFRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "meetkat_csrf_rejections_total",
+		Help: "State-changing requests rejected for a missing or mismatched CSRF token.",
+	})
+
+	SpamRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "meetkat_spam_rejections_total",
+		Help: "Form submissions rejected as suspected spam, by reason.",
+	}, []string{"reason"})
+
+	PollsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "meetkat_polls_created_total",
+		Help: "Polls created.",
+	})
+
+	VotesCast = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "meetkat_votes_cast_total",
+		Help: "Votes added to polls.",
+	})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "meetkat_db_query_duration_seconds",
+		Help:    "Duration of SQLite repository operations.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		RateLimitRejections,
+		CSRFRejections,
+		SpamRejections,
+		PollsCreated,
+		VotesCast,
+		DBQueryDuration,
+	)
+}
+
+// RegisterDB exports the connection pool statistics of db, including the
+// number of open connections.
+func RegisterDB(db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, "meetkat"))
+}
+
+// CacheStats reports the hit and miss counts and the size of a cache.
+type CacheStats func() (hits, misses uint64, entries int)
+
+// RegisterCache exports the statistics of the poll cache.
+func RegisterCache(stats CacheStats) {
+	Registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "meetkat_poll_cache_hits_total",
+			Help: "Poll lookups answered from the cache.",
+		}, func() float64 {
+			hits, _, _ := stats()
+			return float64(hits)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "meetkat_poll_cache_misses_total",
+			Help: "Poll lookups that went to the database.",
+		}, func() float64 {
+			_, misses, _ := stats()
+			return float64(misses)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "meetkat_poll_cache_entries",
+			Help: "Polls currently held in the cache.",
+		}, func() float64 {
+			_, _, entries := stats()
+			return float64(entries)
+		}),
+	)
+}
+
+// ObserveQuery records the duration of a database operation started at
+// start. It is meant to be deferred.
+func ObserveQuery(operation string, start time.Time) {
+	DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestHandlerExposesMetrics(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	RegisterDB(db)
+	RegisterCache(func() (uint64, uint64, int) { return 7, 3, 2 })
+
+	HTTPRequests.WithLabelValues("/poll/:id", "GET", "200").Inc()
+	RateLimitRejections.WithLabelValues("/new").Inc()
+	PollsCreated.Inc()
+	ObserveQuery("get_by_public_id", time.Now())
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`meetkat_http_requests_total{method="GET",route="/poll/:id",status="200"} 1`,
+		`meetkat_ratelimit_rejections_total{route="/new"} 1`,
+		`meetkat_polls_created_total 1`,
+		`meetkat_db_query_duration_seconds_count{operation="get_by_public_id"} 1`,
+		`meetkat_poll_cache_hits_total 7`,
+		`meetkat_poll_cache_misses_total 3`,
+		`meetkat_poll_cache_entries 2`,
+		`go_sql_open_connections{db_name="meetkat"}`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"meetkat/internal/poll"
+)
+
+// Repository is a poll.Repository decorator that records the duration of
+// every operation in DBQueryDuration and counts cast votes. It keeps the poll
+// and storage packages free of Prometheus. Created polls are counted by the
+// handler instead, since imported polls are stored through Create as well.
+type Repository struct {
+	inner poll.Repository
+}
+
+// NewRepository wraps inner with instrumentation. Wrap the storage
+// repository itself, below any cache, so that only real queries are timed.
+func NewRepository(inner poll.Repository) *Repository {
+	return &Repository{inner: inner}
+}
+
+func (r *Repository) Create(ctx context.Context, p *poll.Poll) error {
+	defer ObserveQuery("create", time.Now())
+	return r.inner.Create(ctx, p)
+}
+
+func (r *Repository) GetByPublicID(ctx context.Context, publicID string) (*poll.Poll, error) {
+	defer ObserveQuery("get_by_public_id", time.Now())
+	return r.inner.GetByPublicID(ctx, publicID)
+}
+
+func (r *Repository) GetByAdminHash(ctx context.Context, adminHash string) (*poll.Poll, error) {
+	defer ObserveQuery("get_by_admin_hash", time.Now())
+	return r.inner.GetByAdminHash(ctx, adminHash)
+}
+
+func (r *Repository) SetAdminHash(ctx context.Context, pollID string, adminHash string) error {
+	defer ObserveQuery("set_admin_hash", time.Now())
+	return r.inner.SetAdminHash(ctx, pollID, adminHash)
+}
+
+func (r *Repository) SetClosedAt(ctx context.Context, pollID string, closedAt time.Time) error {
+	defer ObserveQuery("set_closed_at", time.Now())
+	return r.inner.SetClosedAt(ctx, pollID, closedAt)
+}
+
+func (r *Repository) AddVote(ctx context.Context, pollID string, vote poll.Vote) error {
+	defer ObserveQuery("add_vote", time.Now())
+	if err := r.inner.AddVote(ctx, pollID, vote); err != nil {
+		return err
+	}
+	VotesCast.Inc()
+	return nil
+}
+
+func (r *Repository) RemoveVote(ctx context.Context, pollID string, voterName string) error {
+	defer ObserveQuery("remove_vote", time.Now())
+	return r.inner.RemoveVote(ctx, pollID, voterName)
+}
+
+func (r *Repository) Delete(ctx context.Context, pollID string) error {
+	defer ObserveQuery("delete", time.Now())
+	return r.inner.Delete(ctx, pollID)
+}
+
+func (r *Repository) UpdateVote(ctx context.Context, pollID string, oldName string, vote poll.Vote) error {
+	defer ObserveQuery("update_vote", time.Now())
+	return r.inner.UpdateVote(ctx, pollID, oldName, vote)
+}
+
+func (r *Repository) ListExpired(ctx context.Context, cutoff time.Time, basis string, limit int) ([]poll.PollSummary, error) {
+	defer ObserveQuery("list_expired", time.Now())
+	return r.inner.ListExpired(ctx, cutoff, basis, limit)
+}
+
+func (r *Repository) DeleteMany(ctx context.Context, pollIDs []string) (int, error) {
+	defer ObserveQuery("delete_many", time.Now())
+	return r.inner.DeleteMany(ctx, pollIDs)
+}
+
+func (r *Repository) ListIDs(ctx context.Context) ([]string, error) {
+	defer ObserveQuery("list_ids", time.Now())
+	return r.inner.ListIDs(ctx)
+}
+
+func (r *Repository) ListSummaries(ctx context.Context, query string, offset, limit int) ([]poll.PollSummary, error) {
+	defer ObserveQuery("list_summaries", time.Now())
+	return r.inner.ListSummaries(ctx, query, offset, limit)
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"meetkat/internal/poll"
+)
+
+// scrape returns the value of the series named exactly series, or 0.
+func scrape(t *testing.T, series string) float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		if value, ok := strings.CutPrefix(sc.Text(), series+" "); ok {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("parse %s: %v", series, err)
+			}
+			return f
+		}
+	}
+	return 0
+}
+
+func TestRepositoryCountsVotes(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepository(poll.NewMemoryRepository())
+	polls := scrape(t, "meetkat_polls_created_total")
+	votes := scrape(t, "meetkat_votes_cast_total")
+
+	p := &poll.Poll{ID: "abc", Title: "Lunch", Options: []string{"Mon"}}
+	if err := repo.Create(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddVote(ctx, p.ID, poll.Vote{Name: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddVote(ctx, "missing", poll.Vote{Name: "Bob"}); err == nil {
+		t.Fatal("expected an error for a missing poll")
+	}
+
+	if got := scrape(t, "meetkat_polls_created_total") - polls; got != 0 {
+		t.Errorf("polls created = %v, want 0 (imports are stored through Create too)", got)
+	}
+	if got := scrape(t, "meetkat_votes_cast_total") - votes; got != 1 {
+		t.Errorf("votes cast = %v, want 1 (failed votes do not count)", got)
+	}
+	if scrape(t, `meetkat_db_query_duration_seconds_count{operation="add_vote"}`) < 2 {
+		t.Error("add_vote queries were not timed")
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+const (
+	basePathContextKey = "base_path"
+	baseURLContextKey  = "base_url"
+)
+
+// BasePath makes the URL prefix the app is served under (e.g. "/meetkat",
+// empty at the root) available to handlers and templates for building links.
+func BasePath(prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(basePathContextKey, prefix)
+		c.Next()
+	}
+}
+
+// BaseURL makes the configured public URL of the app (e.g.
+// "https://tools.example.com/meetkat") available to handlers for absolute
+// links. When it is empty, handlers fall back to the request's Host header.
+func BaseURL(url string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(baseURLContextKey, url)
+		c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CookieConfig holds the attributes of cookies set by the middleware.
+type CookieConfig struct {
+	// Secure is "auto" (set on HTTPS requests, see IsHTTPS), "always" or
+	// "never".
+	Secure   string
+	SameSite http.SameSite
+	// Domain is empty for host-only cookies.
+	Domain string
+	// Path limits cookies to the app's base path; empty means "/".
+	Path string
+}
+
+// DefaultCookieConfig returns the attributes used when nothing is configured.
+func DefaultCookieConfig() CookieConfig {
+	return CookieConfig{Secure: "auto", SameSite: http.SameSiteLaxMode}
+}
+
+// ParseSameSite maps "lax", "strict" or "none" to its http.SameSite value.
+func ParseSameSite(s string) http.SameSite {
+	switch s {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+func (cc CookieConfig) secure(c *gin.Context) bool {
+	switch cc.Secure {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return IsHTTPS(c)
+	}
+}
+
+func (cc CookieConfig) set(c *gin.Context, name, value string, maxAge int, httpOnly bool) {
+	c.SetSameSite(cc.SameSite)
+	path := cc.Path
+	if path == "" {
+		path = "/"
+	}
+	c.SetCookie(name, value, maxAge, path, cc.Domain, cc.secure(c), httpOnly)
+}
+
+// Set writes an HttpOnly cookie with the configured attributes.
+func (cc CookieConfig) Set(c *gin.Context, name, value string, maxAge int) {
+	cc.set(c, name, value, maxAge, true)
+}
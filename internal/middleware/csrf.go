@@ -1,9 +1,20 @@
 package middleware
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"meetkat/internal/metrics"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,32 +22,89 @@ import (
 const csrfCookieName = "meetkat_csrf"
 const csrfContextKey = "csrf_token"
 
-// CSRF implements the Double Submit Cookie pattern.
-// A random token is stored in an HttpOnly cookie and injected into the Gin context.
-// State-changing requests must present the same token via X-CSRF-Token header or
-// a csrf_token form field.
-func CSRF() gin.HandlerFunc {
+// csrfHeader carries the token on fetch requests, and a fresh one on every
+// response so that long-lived pages can replace theirs before it expires.
+const csrfHeader = "X-CSRF-Token"
+
+// CSRFConfig configures the CSRF middleware.
+type CSRFConfig struct {
+	// Key signs tokens.
+	Key []byte
+	// Cookies holds the attributes of the session cookie.
+	Cookies CookieConfig
+	// MaxAge is how long a token stays valid. Every response carries a new
+	// one, so this only limits how long a page may stay open.
+	MaxAge time.Duration
+	// TrustedOrigins lists origins ("https://meet.example.com") allowed in
+	// the Origin and Referer headers besides the request's own.
+	TrustedOrigins []string
+	// Exempt lists routes as "METHOD /path" patterns relative to BasePath
+	// that are not checked, for requests authenticated in other ways.
+	Exempt []string
+	// BasePath is stripped from route patterns before matching Exempt.
+	BasePath string
+}
+
+// CSRF protects state-changing requests with signed tokens bound to a
+// session cookie. The cookie holds a random identifier; a token is
+// "<unix time>.<HMAC of identifier and time>", so it cannot be forged
+// without the key, does not work with another browser's cookie, and expires.
+// State-changing requests must present a valid token via the X-CSRF-Token
+// header or a csrf_token form field, and must not come from a foreign origin
+// according to their Origin or Referer header.
+func CSRF(cfg CSRFConfig) gin.HandlerFunc {
+	sign := func(session string, issued int64) string {
+		mac := hmac.New(sha256.New, cfg.Key)
+		_, _ = mac.Write([]byte(session + "." + strconv.FormatInt(issued, 10)))
+		return strconv.FormatInt(issued, 10) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+	valid := func(session, token string, now time.Time) bool {
+		unix, _, _ := strings.Cut(token, ".")
+		issued, err := strconv.ParseInt(unix, 10, 64)
+		if err != nil {
+			return false
+		}
+		age := now.Sub(time.Unix(issued, 0))
+		return age < cfg.MaxAge && age > -time.Minute &&
+			hmac.Equal([]byte(token), []byte(sign(session, issued)))
+	}
+
 	return func(c *gin.Context) {
-		token, err := c.Cookie(csrfCookieName)
-		if err != nil || token == "" {
+		session, err := c.Cookie(csrfCookieName)
+		if err != nil || session == "" {
 			b := make([]byte, 16)
 			if _, err := rand.Read(b); err != nil {
 				c.AbortWithStatus(http.StatusInternalServerError)
 				return
 			}
-			token = hex.EncodeToString(b)
-			secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
-			c.SetCookie(csrfCookieName, token, 0, "/", "", secure, true)
+			session = hex.EncodeToString(b)
+			cfg.Cookies.set(c, csrfCookieName, session, 0, true)
 		}
+		now := time.Now()
+		token := sign(session, now.Unix())
 		c.Set(csrfContextKey, token)
+		c.Header(csrfHeader, token)
 
 		switch c.Request.Method {
 		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
-			submitted := c.GetHeader("X-CSRF-Token")
+			if slices.Contains(cfg.Exempt, c.Request.Method+" "+strings.TrimPrefix(c.FullPath(), cfg.BasePath)) {
+				break
+			}
+			reason := ""
+			submitted := c.GetHeader(csrfHeader)
 			if submitted == "" {
 				submitted = c.PostForm("csrf_token")
 			}
-			if submitted == "" || submitted != token {
+			if !valid(session, submitted, now) {
+				reason = "token"
+			} else if !sameOrigin(c, cfg.TrustedOrigins) {
+				reason = "origin"
+			}
+			if reason != "" {
+				metrics.CSRFRejections.Inc()
+				if l, ok := c.Get(loggerContextKey); ok {
+					l.(*slog.Logger).Info("csrf check failed", "reason", reason)
+				}
 				c.AbortWithStatus(http.StatusForbidden)
 				return
 			}
@@ -44,3 +112,25 @@ func CSRF() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// sameOrigin reports whether the Origin header, or the Referer when there is
+// no Origin, names this site or a trusted origin. Requests with neither pass,
+// since some clients and privacy settings strip both; the token still
+// protects them. For this site only the host is compared, because behind a
+// proxy that is not trusted the scheme seen here may differ from the
+// browser's.
+func sameOrigin(c *gin.Context, trusted []string) bool {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		origin = c.GetHeader("Referer")
+		if origin == "" {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false // includes the opaque origin "null"
+	}
+	return strings.EqualFold(u.Host, c.Request.Host) ||
+		slices.ContainsFunc(trusted, func(t string) bool { return strings.EqualFold(u.Scheme+"://"+u.Host, t) })
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var csrfTestKey = []byte("test-key")
+
+func newCSRFRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CSRF(CSRFConfig{
+		Key:            csrfTestKey,
+		Cookies:        DefaultCookieConfig(),
+		MaxAge:         time.Hour,
+		TrustedOrigins: []string{"https://meet.example.org"},
+		Exempt:         []string{"POST /hook"},
+		BasePath:       "/app",
+	}))
+	ok := func(c *gin.Context) { c.String(http.StatusOK, c.GetString(csrfContextKey)) }
+	r.GET("/app/form", ok)
+	r.POST("/app/submit", ok)
+	r.POST("/app/hook", ok)
+	return r
+}
+
+// csrfToken signs a token the way the middleware does, for any time.
+func csrfToken(session string, issued time.Time) string {
+	unix := strconv.FormatInt(issued.Unix(), 10)
+	mac := hmac.New(sha256.New, csrfTestKey)
+	_, _ = mac.Write([]byte(session + "." + unix))
+	return unix + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestCSRF(t *testing.T) {
+	r := newCSRFRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://localhost/app/form", nil))
+	var session string
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			session = c.Value
+		}
+	}
+	token := w.Body.String()
+	if session == "" || token == "" || w.Header().Get("X-CSRF-Token") != token {
+		t.Fatalf("GET issued cookie %q and token %q (header %q)", session, token, w.Header().Get("X-CSRF-Token"))
+	}
+
+	tests := []struct {
+		name            string
+		path            string
+		session, token  string
+		origin, referer string
+		want            int
+	}{
+		{"valid", "/app/submit", session, token, "", "", http.StatusOK},
+		{"same origin", "/app/submit", session, token, "http://localhost", "", http.StatusOK},
+		{"trusted origin", "/app/submit", session, token, "https://meet.example.org", "", http.StatusOK},
+		{"same-site referer", "/app/submit", session, token, "", "http://localhost/app/form", http.StatusOK},
+		{"missing token", "/app/submit", session, "", "", "", http.StatusForbidden},
+		{"other session", "/app/submit", "0123456789abcdef0123456789abcdef", token, "", "", http.StatusForbidden},
+		{"forged signature", "/app/submit", session, token[:len(token)-4] + "AAAA", "", "", http.StatusForbidden},
+		{"expired", "/app/submit", session, csrfToken(session, time.Now().Add(-2*time.Hour)), "", "", http.StatusForbidden},
+		{"still valid", "/app/submit", session, csrfToken(session, time.Now().Add(-50*time.Minute)), "", "", http.StatusOK},
+		{"foreign origin", "/app/submit", session, token, "https://evil.example", "", http.StatusForbidden},
+		{"opaque origin", "/app/submit", session, token, "null", "", http.StatusForbidden},
+		{"foreign referer", "/app/submit", session, token, "", "https://evil.example/page", http.StatusForbidden},
+		{"exempt route", "/app/hook", session, "", "https://evil.example", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost"+tt.path, nil)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: tt.session})
+		if tt.token != "" {
+			req.Header.Set("X-CSRF-Token", tt.token)
+		}
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.referer != "" {
+			req.Header.Set("Referer", tt.referer)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
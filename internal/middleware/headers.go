@@ -1,20 +1,48 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 )
 
-// SecurityHeaders adds HTTP security headers to every response.
-func SecurityHeaders() gin.HandlerFunc {
+const cspNonceContextKey = "csp_nonce"
+
+// SecurityHeaders adds HTTP security headers to every response. Inline
+// scripts only run with the per-request nonce stored in the context as
+// "csp_nonce"; violations are reported to reportURI unless it is empty.
+// reportURI is an absolute URL or a path; the Reporting API only accepts
+// absolute URLs, so a path is resolved against the request's origin for the
+// Reporting-Endpoints header. The scheme middleware must run first.
+func SecurityHeaders(reportURI string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b) // never fails, see crypto/rand.Read
+		nonce := base64.RawURLEncoding.EncodeToString(b)
+		c.Set(cspNonceContextKey, nonce)
+
 		c.Header("X-Content-Type-Options", "nosniff")
 		c.Header("X-Frame-Options", "DENY")
 		c.Header("Referrer-Policy", "strict-origin-when-cross-origin")
-		// 'unsafe-inline' is required for the theme-init <script> block in base.html
-		// and for Tailwind utility classes applied via style attributes.
-		c.Header("Content-Security-Policy",
-			"default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; font-src 'self'")
-		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		// 'unsafe-inline' for styles is still required for the icon masks
+		// applied via style attributes.
+		csp := "default-src 'self'; script-src 'self' 'nonce-" + nonce + "'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; font-src 'self'"
+		if reportURI != "" {
+			csp += "; report-uri " + reportURI + "; report-to csp"
+			endpoint := reportURI
+			if strings.HasPrefix(endpoint, "/") {
+				scheme := "http"
+				if IsHTTPS(c) {
+					scheme = "https"
+				}
+				endpoint = scheme + "://" + c.Request.Host + endpoint
+			}
+			c.Header("Reporting-Endpoints", `csp="`+endpoint+`"`)
+		}
+		c.Header("Content-Security-Policy", csp)
+		if IsHTTPS(c) {
 			c.Header("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
 		}
 		c.Next()
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requestIDHeader  = "X-Request-ID"
+	loggerContextKey = "logger"
+	maxRequestIDLen  = 128
+)
+
+// RequestLogger assigns every request an ID, stores a logger carrying that
+// ID in the Gin context under "logger", and logs one line per request once
+// it completes. A well-formed X-Request-ID from the client or a proxy is
+// reused; otherwise a random one is generated. Either way it is echoed in
+// the response.
+func RequestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(requestIDHeader, id)
+		reqLogger := logger.With("request_id", id)
+		c.Set(loggerContextKey, reqLogger)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", RedactPath(c.Request.URL.Path)),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		reqLogger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery turns a panic into a 500 response and logs it with the request's
+// logger. Unlike gin.Recovery it does not dump the request, whose path and
+// cookies may carry admin IDs; the access log line of RequestLogger, which
+// must run first, records the request with the path redacted.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			logger := slog.Default()
+			if l, ok := c.Get(loggerContextKey); ok {
+				logger = l.(*slog.Logger)
+			}
+			// A client that went away is not worth a stack trace, and
+			// nothing can be written to it anymore.
+			if err, ok := v.(error); ok && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)) {
+				logger.Warn("connection closed by client", "err", err)
+				c.Abort()
+				return
+			}
+			logger.Error("panic recovered", "panic", v, "stack", string(debug.Stack()))
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}()
+		c.Next()
+	}
+}
+
+// RedactPath replaces the admin ID in .../poll/<id>/admin/... paths, with or
+// without a base path in front, so that logs never contain credentials that
+// grant admin access.
+func RedactPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 1; i+2 < len(segments); i++ {
+		if segments[i] == "poll" && segments[i+2] == "admin" {
+			segments[i+1] = "REDACTED"
+			return strings.Join(segments, "/")
+		}
+	}
+	return path
+}
+
+// validRequestID accepts IDs made of printable ASCII without spaces, so a
+// client cannot inject log lines or oversized values.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
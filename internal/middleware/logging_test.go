@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newLoggedRouter(buf *bytes.Buffer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestLogger(slog.New(slog.NewJSONHandler(buf, nil))))
+	r.GET("/poll/:id/admin", func(c *gin.Context) {
+		c.MustGet(loggerContextKey).(*slog.Logger).Info("handler log")
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestRequestLoggerPropagatesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	r := newLoggedRouter(&buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/secretadmin/admin", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Request-ID"); got != "abc-123" {
+		t.Errorf("response X-Request-ID: got %q, want abc-123", got)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected handler and access log lines, got %q", buf.String())
+	}
+	for _, line := range lines {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("decode log line: %v", err)
+		}
+		if entry["request_id"] != "abc-123" {
+			t.Errorf("log line without request ID: %s", line)
+		}
+	}
+	if strings.Contains(buf.String(), "secretadmin") {
+		t.Errorf("admin ID leaked into logs: %s", buf.String())
+	}
+	if !strings.Contains(lines[1], `"path":"/poll/REDACTED/admin"`) {
+		t.Errorf("expected redacted path, got %s", lines[1])
+	}
+}
+
+func TestRequestLoggerReplacesInvalidRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	r := newLoggedRouter(&buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/x/admin", nil)
+	req.Header.Set("X-Request-ID", "bad id\nwith newline")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	got := w.Header().Get("X-Request-ID")
+	if len(got) != 32 || strings.ContainsAny(got, " \n") {
+		t.Errorf("expected generated request ID, got %q", got)
+	}
+}
+
+func TestRedactPath(t *testing.T) {
+	tests := map[string]string{
+		"/poll/abc/admin":         "/poll/REDACTED/admin",
+		"/poll/abc/admin/export":  "/poll/REDACTED/admin/export",
+		"/poll/abc":               "/poll/abc",
+		"/poll/abc/vote":          "/poll/abc/vote",
+		"/new":                    "/new",
+		"/meetkat/poll/abc/admin": "/meetkat/poll/REDACTED/admin",
+		"/meetkat/poll/abc":       "/meetkat/poll/abc",
+	}
+	for in, want := range tests {
+		if got := RedactPath(in); got != want {
+			t.Errorf("RedactPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRecoveryLogsWithoutRequestDump(t *testing.T) {
+	var buf bytes.Buffer
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestLogger(slog.New(slog.NewJSONHandler(&buf, nil))), Recovery())
+	r.GET("/poll/:id/admin", func(c *gin.Context) { panic("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/secretadmin/admin", nil)
+	req.AddCookie(&http.Cookie{Name: "meetkat_access", Value: "secretcookie"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if !strings.Contains(buf.String(), `"msg":"panic recovered"`) || !strings.Contains(buf.String(), `"panic":"boom"`) {
+		t.Errorf("panic not logged: %s", buf.String())
+	}
+	if strings.Contains(buf.String(), "secretadmin") || strings.Contains(buf.String(), "secretcookie") {
+		t.Errorf("request details leaked into logs: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"path":"/poll/REDACTED/admin","route":"/poll/:id/admin","status":500`) {
+		t.Errorf("no redacted access log line: %s", buf.String())
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"meetkat/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records the count and latency of every request, labelled by the
+// matched route pattern rather than the raw path so that poll IDs do not
+// create new series.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route, method := c.FullPath(), c.Request.Method
+		if route == "" {
+			route, method = "unmatched", "other"
+		}
+		metrics.HTTPRequests.WithLabelValues(route, method, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	}
+}
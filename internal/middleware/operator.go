@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OperatorUser is the basic auth user name of the operator console.
+const OperatorUser = "operator"
+
+// OperatorAuth guards the operator console with HTTP basic auth. Credentials
+// are compared as SHA-256 digests in constant time, so neither their content
+// nor their length leaks through timing. Failed attempts are logged.
+func OperatorAuth(password string) gin.HandlerFunc {
+	wantUser := sha256.Sum256([]byte(OperatorUser))
+	wantPassword := sha256.Sum256([]byte(password))
+	return func(c *gin.Context) {
+		user, pass, ok := c.Request.BasicAuth()
+		gotUser := sha256.Sum256([]byte(user))
+		gotPassword := sha256.Sum256([]byte(pass))
+		// Both comparisons always run.
+		userOK := subtle.ConstantTimeCompare(gotUser[:], wantUser[:])
+		passwordOK := subtle.ConstantTimeCompare(gotPassword[:], wantPassword[:])
+		if !ok || userOK&passwordOK != 1 {
+			if ok {
+				if l, exists := c.Get(loggerContextKey); exists {
+					l.(*slog.Logger).Warn("operator login failed", "user", user)
+				}
+			}
+			c.Header("WWW-Authenticate", `Basic realm="meetkat operator", charset="UTF-8"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOperatorAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(OperatorAuth("correct horse battery"))
+	r.GET("/operator", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name, user, password string
+		noAuth               bool
+		want                 int
+	}{
+		{"valid", "operator", "correct horse battery", false, http.StatusOK},
+		{"wrong password", "operator", "correct horse", false, http.StatusUnauthorized},
+		{"wrong user", "admin", "correct horse battery", false, http.StatusUnauthorized},
+		{"empty password", "operator", "", false, http.StatusUnauthorized},
+		{"no credentials", "", "", true, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/operator", nil)
+		if !tt.noAuth {
+			req.SetBasicAuth(tt.user, tt.password)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate challenge", tt.name)
+		}
+	}
+}
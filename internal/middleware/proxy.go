@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const httpsContextKey = "https"
+
+// ParseTrustedProxies parses IP addresses and CIDRs into prefixes.
+func ParseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	return parsePrefixes("trusted proxy", proxies)
+}
+
+// ParseRateLimitAllowlist parses the IP addresses and CIDRs exempt from rate
+// limiting into prefixes.
+func ParseRateLimitAllowlist(allow []string) ([]netip.Prefix, error) {
+	return parsePrefixes("rate limit allowlist entry", allow)
+}
+
+func parsePrefixes(what string, list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, p := range list {
+		if addr, err := netip.ParseAddr(p); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: not an IP address or CIDR", what, p)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Scheme records whether the client reached us over HTTPS, for IsHTTPS.
+// X-Forwarded-Proto is only believed when the direct peer is one of the
+// trusted proxies, or from any peer when trustPlatform is set because all
+// traffic arrives through a platform load balancer. Otherwise a client could
+// claim HTTPS on a plain connection.
+func Scheme(trusted []netip.Prefix, trustPlatform bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		https := c.Request.TLS != nil
+		if !https && (trustPlatform || isTrustedPeer(c, trusted)) {
+			// A chain of proxies appends; the first value is the client-facing one.
+			proto, _, _ := strings.Cut(c.GetHeader("X-Forwarded-Proto"), ",")
+			https = strings.EqualFold(strings.TrimSpace(proto), "https")
+		}
+		c.Set(httpsContextKey, https)
+		c.Next()
+	}
+}
+
+// IsHTTPS reports whether the request was made over HTTPS as determined by
+// Scheme. Without Scheme only the connection itself is considered.
+func IsHTTPS(c *gin.Context) bool {
+	if v, ok := c.Get(httpsContextKey); ok {
+		return v.(bool)
+	}
+	return c.Request.TLS != nil
+}
+
+func isTrustedPeer(c *gin.Context, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newProxyRouter builds a router trusting proxies in 10.0.0.0/8 with the
+// security middleware and a rate-limited endpoint.
+func newProxyRouter(t *testing.T, platformHeader string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	proxies := []string{"10.0.0.0/8"}
+	trusted, err := ParseTrustedProxies(proxies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		t.Fatal(err)
+	}
+	r.TrustedPlatform = platformHeader
+	r.Use(Scheme(trusted, platformHeader != ""))
+	r.Use(SecurityHeaders(""))
+	r.Use(CSRF(CSRFConfig{Key: []byte("test-key"), Cookies: DefaultCookieConfig(), MaxAge: time.Hour}))
+	limiter := NewRateLimiter(1, 1)
+	r.GET("/", limiter.Middleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	return r
+}
+
+func proxyRequest(r http.Handler, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func isSecureCookie(w *httptest.ResponseRecorder) bool {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			return c.Secure
+		}
+	}
+	return false
+}
+
+func TestSchemeIgnoresForwardedProtoFromUntrustedPeer(t *testing.T) {
+	r := newProxyRouter(t, "")
+	w := proxyRequest(r, "203.0.113.5:1234", map[string]string{"X-Forwarded-Proto": "https"})
+
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS sent for spoofed X-Forwarded-Proto")
+	}
+	if isSecureCookie(w) {
+		t.Error("Secure cookie set for spoofed X-Forwarded-Proto")
+	}
+}
+
+func TestSchemeBelievesTrustedProxy(t *testing.T) {
+	r := newProxyRouter(t, "")
+	w := proxyRequest(r, "10.1.2.3:1234", map[string]string{"X-Forwarded-Proto": "https"})
+
+	if w.Header().Get("Strict-Transport-Security") == "" {
+		t.Error("HSTS missing behind trusted HTTPS proxy")
+	}
+	if !isSecureCookie(w) {
+		t.Error("cookie not Secure behind trusted HTTPS proxy")
+	}
+}
+
+func TestSchemeDetectsNativeTLS(t *testing.T) {
+	r := newProxyRouter(t, "")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.5:1234"
+	req.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Header().Get("Strict-Transport-Security") == "" {
+		t.Error("HSTS missing on a direct TLS connection")
+	}
+	if !isSecureCookie(w) {
+		t.Error("cookie not Secure on a direct TLS connection")
+	}
+}
+
+func TestSchemeUsesFirstForwardedProto(t *testing.T) {
+	r := newProxyRouter(t, "")
+	w := proxyRequest(r, "10.1.2.3:1234", map[string]string{"X-Forwarded-Proto": "http, https"})
+
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS sent although the client-facing proxy saw plain HTTP")
+	}
+}
+
+func TestRateLimiterIgnoresSpoofedForwardedFor(t *testing.T) {
+	r := newProxyRouter(t, "")
+
+	w := proxyRequest(r, "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request: got %d", w.Code)
+	}
+	if got := w.Body.String(); got != "203.0.113.5" {
+		t.Errorf("ClientIP = %q, want peer address", got)
+	}
+	w = proxyRequest(r, "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.2"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("rotating X-Forwarded-For bypassed the rate limit: got %d", w.Code)
+	}
+}
+
+func TestRateLimiterUsesForwardedForFromTrustedProxy(t *testing.T) {
+	r := newProxyRouter(t, "")
+
+	for _, client := range []string{"198.51.100.1", "198.51.100.2"} {
+		w := proxyRequest(r, "10.1.2.3:1234", map[string]string{"X-Forwarded-For": client})
+		if w.Code != http.StatusOK {
+			t.Errorf("client %s behind trusted proxy: got %d", client, w.Code)
+		}
+		if got := w.Body.String(); got != client {
+			t.Errorf("ClientIP = %q, want %s", got, client)
+		}
+	}
+}
+
+func TestTrustedPlatformHeader(t *testing.T) {
+	r := newProxyRouter(t, "CF-Connecting-IP")
+	w := proxyRequest(r, "203.0.113.5:1234", map[string]string{
+		"CF-Connecting-IP":  "198.51.100.7",
+		"X-Forwarded-Proto": "https",
+	})
+
+	if got := w.Body.String(); got != "198.51.100.7" {
+		t.Errorf("ClientIP = %q, want platform header value", got)
+	}
+	if !isSecureCookie(w) {
+		t.Error("X-Forwarded-Proto not believed with a trusted platform")
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := ParseTrustedProxies([]string{"10.0.0.1", "192.168.0.0/16", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range prefixes {
+		got = append(got, p.String())
+	}
+	if want := "10.0.0.1/32 192.168.0.0/16 ::1/128"; strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+	if _, err := ParseTrustedProxies([]string{"proxy.local"}); err == nil {
+		t.Error("expected error for host name")
+	}
+}
+
+func TestReportingEndpointIsAbsolute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		reportURI, want string
+	}{
+		{"/meetkat/csp-report", `csp="https://meet.example.org/meetkat/csp-report"`},
+		{"https://tools.example.com/meetkat/csp-report", `csp="https://tools.example.com/meetkat/csp-report"`},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		r.Use(Scheme(trusted, false))
+		r.Use(SecurityHeaders(tt.reportURI))
+		r.GET("/", func(c *gin.Context) {})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = "meet.example.org"
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-Proto", "https")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Reporting-Endpoints"); got != tt.want {
+			t.Errorf("%s: Reporting-Endpoints = %q, want %q", tt.reportURI, got, tt.want)
+		}
+		if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "report-uri "+tt.reportURI+";") {
+			t.Errorf("%s: CSP %q lacks report-uri", tt.reportURI, csp)
+		}
+	}
+}
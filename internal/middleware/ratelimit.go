@@ -1,63 +1,239 @@
 package middleware
 
 import (
+	"hash/maphash"
+	"math"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"meetkat/internal/metrics"
+
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	rateLimitedContextKey = "rate_limited"
+
+	// rateLimitShards spreads clients over independently locked maps so that
+	// concurrent requests rarely wait for each other.
+	rateLimitShards = 64
+	// maxRateLimitClients bounds the memory of one limiter. When a shard is
+	// full even after dropping idle buckets, the stalest of a few random
+	// clients is forgotten, which only ever gives that client a fresh burst.
+	maxRateLimitClients = 1 << 16
+	evictionSamples     = 8
+)
+
 type bucket struct {
 	tokens    float64
 	lastCheck time.Time
 }
 
+type rateLimitShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	nextSweep time.Time
+}
+
 // RateLimiter is a per-IP token bucket rate limiter backed by stdlib only.
+// Buckets that have been idle long enough to be full again are dropped, as
+// they behave exactly like a client that was never seen.
 type RateLimiter struct {
-	mu      sync.Mutex
-	buckets sync.Map
-	rate    float64 // tokens per second
-	burst   float64 // max burst size
+	rate   float64 // tokens per second
+	burst  float64 // max burst size
+	idle   time.Duration
+	shards [rateLimitShards]rateLimitShard
+	seed   maphash.Seed
+
+	now func() time.Time
 }
 
 // NewRateLimiter creates a limiter that allows ratePerMinute requests per minute
 // with an initial burst capacity of burst tokens.
 func NewRateLimiter(ratePerMinute, burst int) *RateLimiter {
-	return &RateLimiter{
+	rl := &RateLimiter{
 		rate:  float64(ratePerMinute) / 60.0,
 		burst: float64(burst),
+		seed:  maphash.MakeSeed(),
+		now:   time.Now,
 	}
+	rl.idle = time.Duration(rl.burst / rl.rate * float64(time.Second))
+	for i := range rl.shards {
+		rl.shards[i].buckets = make(map[string]*bucket)
+	}
+	return rl
 }
 
 // Middleware returns a Gin handler that enforces the rate limit per client IP.
 func (rl *RateLimiter) Middleware() gin.HandlerFunc {
+	return rl.limit
+}
+
+// limit takes a token for the client and sets the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers (seconds until the bucket
+// is full again). Without a token left it answers 429 with Retry-After.
+func (rl *RateLimiter) limit(c *gin.Context) {
+	tokens, ok := rl.take(c.ClientIP())
+
+	h := c.Writer.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(int(rl.burst)))
+	h.Set("RateLimit-Remaining", strconv.Itoa(int(tokens)))
+	h.Set("RateLimit-Reset", strconv.Itoa(rl.secondsUntil(rl.burst-tokens)))
+	if !ok {
+		h.Set("Retry-After", strconv.Itoa(rl.secondsUntil(1-tokens)))
+		metrics.RateLimitRejections.WithLabelValues(c.FullPath()).Inc()
+		c.AbortWithStatus(http.StatusTooManyRequests)
+		return
+	}
+	c.Next()
+}
+
+// take removes a token from the client's bucket if it has one and returns
+// the tokens left.
+func (rl *RateLimiter) take(ip string) (float64, bool) {
+	now := rl.now()
+	s := &rl.shards[maphash.String(rl.seed, ip)%rateLimitShards]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[ip]
+	if !ok {
+		s.evict(now, rl.idle)
+		b = &bucket{tokens: rl.burst, lastCheck: now}
+		s.buckets[ip] = b
+	}
+	elapsed := now.Sub(b.lastCheck).Seconds()
+	b.tokens = min(rl.burst, b.tokens+elapsed*rl.rate)
+	b.lastCheck = now
+	if b.tokens < 1 {
+		return b.tokens, false
+	}
+	b.tokens--
+	return b.tokens, true
+}
+
+// evict makes room for a new bucket. Idle buckets are swept at most once per
+// idle period; if the shard is still full, the stalest of a random sample
+// goes (map iteration order is random).
+func (s *rateLimitShard) evict(now time.Time, idle time.Duration) {
+	if now.After(s.nextSweep) {
+		for ip, b := range s.buckets {
+			if now.Sub(b.lastCheck) >= idle {
+				delete(s.buckets, ip)
+			}
+		}
+		s.nextSweep = now.Add(max(idle, time.Second))
+	}
+	if len(s.buckets) < maxRateLimitClients/rateLimitShards {
+		return
+	}
+	var oldest string
+	n := 0
+	for ip, b := range s.buckets {
+		if oldest == "" || b.lastCheck.Before(s.buckets[oldest].lastCheck) {
+			oldest = ip
+		}
+		if n++; n == evictionSamples {
+			break
+		}
+	}
+	delete(s.buckets, oldest)
+}
+
+// secondsUntil returns how long it takes to refill n tokens, rounded up.
+func (rl *RateLimiter) secondsUntil(n float64) int {
+	if n <= 0 {
+		return 0
+	}
+	return int(math.Ceil(n / rl.rate))
+}
+
+// RateLimitPolicy is the rate limit of a route in requests per minute, with
+// burst capacity.
+type RateLimitPolicy struct {
+	PerMinute int
+	Burst     int
+}
+
+// RateLimits applies per-route rate limiting policies and an allowlist on
+// top of the limiters attached to routes. Its Middleware must run before the
+// route handlers; it limits routes that have a policy of their own, and Limit
+// then leaves those requests, and those of allowlisted clients, alone.
+type RateLimits struct {
+	basePath string
+	routes   map[string]*RateLimiter // "METHOD /path" relative to basePath
+	allow    []netip.Prefix
+}
+
+// NewRateLimits creates rate limits with the given per-route policies, keyed
+// by method and route pattern relative to basePath, e.g. "POST /poll/:id/vote".
+func NewRateLimits(basePath string, routes map[string]RateLimitPolicy, allow []netip.Prefix) *RateLimits {
+	rs := &RateLimits{basePath: basePath, routes: make(map[string]*RateLimiter, len(routes)), allow: allow}
+	for route, p := range routes {
+		rs.routes[route] = NewRateLimiter(p.PerMinute, p.Burst)
+	}
+	return rs
+}
+
+// Middleware returns a Gin handler that exempts allowlisted clients and
+// enforces the policies of routes that have one.
+func (rs *RateLimits) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		now := time.Now()
-
-		val, _ := rl.buckets.LoadOrStore(ip, &bucket{tokens: rl.burst, lastCheck: now})
-		b := val.(*bucket)
-
-		rl.mu.Lock()
-		elapsed := now.Sub(b.lastCheck).Seconds()
-		b.tokens = minFloat(rl.burst, b.tokens+elapsed*rl.rate)
-		b.lastCheck = now
-		if b.tokens < 1 {
-			rl.mu.Unlock()
-			c.AbortWithStatus(http.StatusTooManyRequests)
+		if rs.allowed(c.ClientIP()) {
+			c.Set(rateLimitedContextKey, true)
+			c.Next()
+			return
+		}
+		route := c.Request.Method + " " + strings.TrimPrefix(c.FullPath(), rs.basePath)
+		if rl, ok := rs.routes[route]; ok {
+			c.Set(rateLimitedContextKey, true)
+			rl.limit(c)
 			return
 		}
-		b.tokens--
-		rl.mu.Unlock()
-
 		c.Next()
 	}
 }
 
-func minFloat(a, b float64) float64 {
-	if a < b {
-		return a
+// Limit returns a Gin handler limiting requests with def unless Middleware
+// already took care of them.
+func (rs *RateLimits) Limit(def *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool(rateLimitedContextKey) {
+			c.Next()
+			return
+		}
+		def.limit(c)
+	}
+}
+
+// Unknown returns the configured routes that are not among the registered
+// ones, so that typos in the configuration are not silently ignored.
+func (rs *RateLimits) Unknown(registered gin.RoutesInfo) []string {
+	var unknown []string
+	for route := range rs.routes {
+		if !slices.ContainsFunc(registered, func(ri gin.RouteInfo) bool {
+			return ri.Method+" "+strings.TrimPrefix(ri.Path, rs.basePath) == route
+		}) {
+			unknown = append(unknown, route)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
+
+func (rs *RateLimits) allowed(ip string) bool {
+	if len(rs.allow) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
 	}
-	return b
+	addr = addr.Unmap()
+	return slices.ContainsFunc(rs.allow, func(p netip.Prefix) bool { return p.Contains(addr) })
 }
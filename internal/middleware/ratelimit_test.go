@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func limitedRequest(r http.Handler, method, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiterHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(6, 2) // one token every 10 seconds
+	rl.now = func() time.Time { return now }
+	r := gin.New()
+	r.GET("/", rl.Middleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		code                         int
+		remaining, reset, retryAfter string
+	}{
+		{http.StatusOK, "1", "10", ""},
+		{http.StatusOK, "0", "20", ""},
+		{http.StatusTooManyRequests, "0", "20", "10"},
+	}
+	for i, tt := range tests {
+		w := limitedRequest(r, http.MethodGet, "/", "203.0.113.5:1234")
+		h := w.Header()
+		if w.Code != tt.code || h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Remaining") != tt.remaining ||
+			h.Get("RateLimit-Reset") != tt.reset || h.Get("Retry-After") != tt.retryAfter {
+			t.Errorf("request %d: %d limit=%s remaining=%s reset=%s retry-after=%q, want %d 2 %s %s %q", i+1,
+				w.Code, h.Get("RateLimit-Limit"), h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset"), h.Get("Retry-After"),
+				tt.code, tt.remaining, tt.reset, tt.retryAfter)
+		}
+	}
+
+	now = now.Add(10 * time.Second)
+	if w := limitedRequest(r, http.MethodGet, "/", "203.0.113.5:1234"); w.Code != http.StatusOK {
+		t.Errorf("after refill: got %d", w.Code)
+	}
+}
+
+func TestRateLimiterEvictsIdleBuckets(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(60, 5) // full again after 5 idle seconds
+	rl.now = func() time.Time { return now }
+
+	for i := range 1000 {
+		rl.take("198.51.100." + strconv.Itoa(i))
+	}
+	if n := rl.size(); n != 1000 {
+		t.Fatalf("size = %d, want 1000", n)
+	}
+	now = now.Add(6 * time.Second)
+	for i := range 1000 {
+		rl.take("203.0.113." + strconv.Itoa(i))
+	}
+	if n := rl.size(); n != 1000 {
+		t.Errorf("size = %d, want idle buckets replaced", n)
+	}
+
+	// Without idle buckets the limiter stays bounded anyway.
+	for i := range 2 * maxRateLimitClients {
+		rl.take("2001:db8::" + strconv.FormatInt(int64(i), 16))
+	}
+	if n := rl.size(); n > maxRateLimitClients {
+		t.Errorf("size = %d, want at most %d", n, maxRateLimitClients)
+	}
+}
+
+func (rl *RateLimiter) size() int {
+	n := 0
+	for i := range rl.shards {
+		n += len(rl.shards[i].buckets)
+	}
+	return n
+}
+
+func TestRateLimitsRoutePolicyAndAllowlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	allow, err := ParseRateLimitAllowlist([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := NewRateLimits("/app", map[string]RateLimitPolicy{"POST /b": {PerMinute: 1, Burst: 3}}, allow)
+	def := limits.Limit(NewRateLimiter(1, 1))
+	r := gin.New()
+	r.Use(limits.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/app/a", def, ok)
+	r.POST("/app/b", def, ok)
+
+	codes := func(path, remoteAddr string) []int {
+		var got []int
+		for range 3 {
+			got = append(got, limitedRequest(r, http.MethodPost, path, remoteAddr).Code)
+		}
+		return got
+	}
+	if got := codes("/app/a", "203.0.113.5:1"); got[0] != http.StatusOK || got[1] != http.StatusTooManyRequests {
+		t.Errorf("default policy: %v, want one request allowed", got)
+	}
+	if got := codes("/app/b", "203.0.113.5:1"); got[2] != http.StatusOK {
+		t.Errorf("route policy: %v, want three requests allowed", got)
+	}
+	if got := codes("/app/a", "10.1.2.3:1"); got[2] != http.StatusOK {
+		t.Errorf("allowlisted client: %v, want no limit", got)
+	}
+
+	if unknown := limits.Unknown(r.Routes()); len(unknown) != 0 {
+		t.Errorf("Unknown = %v, want none", unknown)
+	}
+	typo := NewRateLimits("/app", map[string]RateLimitPolicy{"POST /c": {PerMinute: 1, Burst: 1}}, nil)
+	if unknown := typo.Unknown(r.Routes()); len(unknown) != 1 || unknown[0] != "POST /c" {
+		t.Errorf("Unknown = %v, want [POST /c]", unknown)
+	}
+}
+
+func BenchmarkRateLimiterSameClient(b *testing.B) {
+	rl := NewRateLimiter(60, 10)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rl.take("203.0.113.5")
+		}
+	})
+}
+
+func BenchmarkRateLimiterManyClients(b *testing.B) {
+	rl := NewRateLimiter(60, 10)
+	ips := make([]string, 4096)
+	for i := range ips {
+		ips[i] = netip.AddrFrom4([4]byte{198, 51, byte(i >> 8), byte(i)}).String()
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			rl.take(ips[i%len(ips)])
+			i++
+		}
+	})
+}
+
+func BenchmarkRateLimiterScan(b *testing.B) {
+	rl := NewRateLimiter(60, 10)
+	b.RunParallel(func(pb *testing.PB) {
+		var n uint32
+		for pb.Next() {
+			n++
+			rl.take(netip.AddrFrom4([4]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}).String())
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"meetkat/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	formTokenContextKey = "form_token"
+	powBitsContextKey   = "pow_bits"
+
+	// Form fields rendered by the spam_fields template. The honeypot is a
+	// text field hidden with CSS, which people never fill in but form-filling
+	// bots usually do.
+	formTokenField = "form_token"
+	honeypotField  = "website"
+	powField       = "pow"
+
+	// formTokenHeader carries a fresh token in responses to guarded requests,
+	// for forms that are submitted with fetch and stay on the page.
+	formTokenHeader = "X-Form-Token"
+)
+
+// SpamConfig configures a SpamGuard.
+type SpamConfig struct {
+	// Enabled turns the checks on; a disabled guard lets everything through.
+	Enabled bool
+	// Key signs form tokens.
+	Key []byte
+	// MinFillTime is how long a form must have been open before it may be
+	// submitted. Faster submissions are taken for bots.
+	MinFillTime time.Duration
+	// MaxAge is how long a form token stays valid.
+	MaxAge time.Duration
+	// PoWBits is the proof-of-work difficulty: the browser has to find a
+	// nonce such that SHA-256(token + ":" + nonce) starts with this many zero
+	// bits. 0 disables the challenge.
+	PoWBits int
+}
+
+// SpamGuard protects public forms against bots without third-party
+// services. Issue puts a signed, timestamped form token into the context for
+// templates; Protect rejects submissions that fill in the honeypot, come back
+// too fast or too late, reuse a token, or lack the proof of work.
+type SpamGuard struct {
+	cfg SpamConfig
+
+	mu        sync.Mutex
+	used      map[string]time.Time // token -> expiry; tokens are single-use
+	nextSweep time.Time
+
+	now func() time.Time
+}
+
+// NewSpamGuard creates a guard with the given configuration.
+func NewSpamGuard(cfg SpamConfig) *SpamGuard {
+	return &SpamGuard{cfg: cfg, used: make(map[string]time.Time), now: time.Now}
+}
+
+// Issue returns a Gin handler that stores a fresh form token for the page
+// being rendered. Use it on the GET routes showing guarded forms.
+func (g *SpamGuard) Issue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if g.cfg.Enabled {
+			g.issue(c)
+		}
+		c.Next()
+	}
+}
+
+// Protect returns a Gin handler that rejects suspected spam. Every guarded
+// request gets a fresh token, both in the context for a re-rendered form and
+// in the X-Form-Token header. A rejected request is answered by reject, which
+// typically re-renders the form with an error; a nil reject answers with a
+// bare 400 Bad Request.
+func (g *SpamGuard) Protect(reject gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !g.cfg.Enabled {
+			c.Next()
+			return
+		}
+		reason := g.check(c)
+		g.issue(c)
+		c.Header(formTokenHeader, c.GetString(formTokenContextKey))
+		if reason != "" {
+			metrics.SpamRejections.WithLabelValues(reason).Inc()
+			if l, ok := c.Get(loggerContextKey); ok {
+				l.(*slog.Logger).Info("suspected spam rejected", "reason", reason)
+			}
+			if reject == nil {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			reject(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (g *SpamGuard) issue(c *gin.Context) {
+	c.Set(formTokenContextKey, g.token(g.now()))
+	c.Set(powBitsContextKey, g.cfg.PoWBits)
+}
+
+// token returns "<unix time>.<random>.<signature>". The random part makes
+// every token unique, so that each can be used only once.
+func (g *SpamGuard) token(issued time.Time) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b) // never fails, see crypto/rand.Read
+	payload := strconv.FormatInt(issued.Unix(), 10) + "." + hex.EncodeToString(b)
+	return payload + "." + g.sign(payload)
+}
+
+func (g *SpamGuard) sign(payload string) string {
+	mac := hmac.New(sha256.New, g.cfg.Key)
+	_, _ = mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// check returns why the request looks like spam, or "" if it does not.
+func (g *SpamGuard) check(c *gin.Context) string {
+	if c.PostForm(honeypotField) != "" {
+		return "honeypot"
+	}
+	token := c.PostForm(formTokenField)
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(g.sign(token[:i]))) {
+		return "token"
+	}
+	unix, _, _ := strings.Cut(token, ".")
+	sec, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return "token"
+	}
+	issued := time.Unix(sec, 0)
+	now := g.now()
+	switch {
+	case now.Sub(issued) < g.cfg.MinFillTime:
+		return "too_fast"
+	case now.Sub(issued) > g.cfg.MaxAge:
+		return "expired"
+	}
+	if g.cfg.PoWBits > 0 && !validWork(token, c.PostForm(powField), g.cfg.PoWBits) {
+		return "proof_of_work"
+	}
+	if !g.consume(token, issued.Add(g.cfg.MaxAge), now) {
+		return "replay"
+	}
+	return ""
+}
+
+// consume marks token as used and reports whether it was still unused.
+// Expired tokens are forgotten at most once a minute.
+func (g *SpamGuard) consume(token string, expires, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now.After(g.nextSweep) {
+		for t, exp := range g.used {
+			if now.After(exp) {
+				delete(g.used, t)
+			}
+		}
+		g.nextSweep = now.Add(time.Minute)
+	}
+	if _, ok := g.used[token]; ok {
+		return false
+	}
+	g.used[token] = expires
+	return true
+}
+
+// validWork reports whether SHA-256(token + ":" + nonce) starts with at
+// least difficulty zero bits.
+func validWork(token, nonce string, difficulty int) bool {
+	if nonce == "" || len(nonce) > 32 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(token + ":" + nonce))
+	zeros := 0
+	for _, b := range sum {
+		zeros += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return zeros >= difficulty
+}
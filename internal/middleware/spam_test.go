@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"math/bits"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newSpamRouter builds a router with a guarded POST /submit on a clock the
+// test can move forward.
+func newSpamRouter(t *testing.T, powBits int) (*gin.Engine, *SpamGuard, *time.Time) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	g := NewSpamGuard(SpamConfig{
+		Enabled:     true,
+		Key:         []byte("test-key"),
+		MinFillTime: 3 * time.Second,
+		MaxAge:      time.Hour,
+		PoWBits:     powBits,
+	})
+	g.now = func() time.Time { return now }
+	r := gin.New()
+	r.POST("/submit", g.Protect(nil), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return r, g, &now
+}
+
+func postForm(r http.Handler, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// solve finds a proof-of-work nonce the way app.js does.
+func solve(token string, difficulty int) string {
+	for n := 0; ; n++ {
+		nonce := strconv.Itoa(n)
+		sum := sha256.Sum256([]byte(token + ":" + nonce))
+		zeros := 0
+		for _, b := range sum {
+			zeros += bits.LeadingZeros8(b)
+			if b != 0 {
+				break
+			}
+		}
+		if zeros >= difficulty {
+			return nonce
+		}
+	}
+}
+
+func TestSpamGuard(t *testing.T) {
+	r, g, now := newSpamRouter(t, 0)
+	token := g.token(*now)
+
+	tests := []struct {
+		name string
+		form url.Values
+		wait time.Duration
+		want int
+	}{
+		{"too fast", url.Values{"form_token": {token}}, time.Second, http.StatusBadRequest},
+		{"missing token", url.Values{}, 5 * time.Second, http.StatusBadRequest},
+		{"forged token", url.Values{"form_token": {token[:len(token)-2] + "xx"}}, 0, http.StatusBadRequest},
+		{"honeypot", url.Values{"form_token": {token}, "website": {"http://spam.example"}}, 0, http.StatusBadRequest},
+		{"valid", url.Values{"form_token": {token}, "website": {""}}, 0, http.StatusNoContent},
+		{"replay", url.Values{"form_token": {token}}, 0, http.StatusBadRequest},
+		{"expired", url.Values{"form_token": {g.token(*now)}}, 2 * time.Hour, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		*now = now.Add(tt.wait)
+		w := postForm(r, tt.form)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if w.Code == http.StatusNoContent && w.Header().Get(formTokenHeader) == "" {
+			t.Errorf("%s: no fresh %s header", tt.name, formTokenHeader)
+		}
+	}
+}
+
+func TestSpamGuardProofOfWork(t *testing.T) {
+	r, g, now := newSpamRouter(t, 8)
+	token := g.token(*now)
+	*now = now.Add(5 * time.Second)
+
+	if w := postForm(r, url.Values{"form_token": {token}}); w.Code != http.StatusBadRequest {
+		t.Errorf("without proof of work: status = %d, want 400", w.Code)
+	}
+	if w := postForm(r, url.Values{"form_token": {token}, "pow": {solve(token, 8)}}); w.Code != http.StatusNoContent {
+		t.Errorf("with proof of work: status = %d, want 204", w.Code)
+	}
+}
+
+func TestSpamGuardDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	g := NewSpamGuard(SpamConfig{})
+	r := gin.New()
+	r.POST("/submit", g.Protect(nil), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	if w := postForm(r, url.Values{"website": {"spam"}}); w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204 with protection disabled", w.Code)
+	}
+}
+
+func TestSpamGuardReject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	g := NewSpamGuard(SpamConfig{Enabled: true, Key: []byte("test-key"), MaxAge: time.Hour})
+	r := gin.New()
+	reject := func(c *gin.Context) {
+		c.String(http.StatusUnprocessableEntity, c.GetString(formTokenContextKey))
+	}
+	r.POST("/submit", g.Protect(reject), func(c *gin.Context) {
+		t.Error("handler ran for a rejected request")
+	})
+
+	w := postForm(r, url.Values{"website": {"spam"}})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want the reject handler's 422", w.Code)
+	}
+	if w.Body.Len() == 0 || w.Body.String() != w.Header().Get(formTokenHeader) {
+		t.Errorf("reject handler got no fresh token: body = %q", w.Body.String())
+	}
+}
@@ -0,0 +1,39 @@
+package poll
+
+import (
+	"context"
+	"fmt"
+)
+
+// Name visibility policies, chosen when a poll is created. Polls that do not
+// show names publicly are anonymous: participant pages only show Totals.
+const (
+	NamesPublic    = "public"     // default: everyone sees who voted what
+	NamesAdminOnly = "admin_only" // only the admin page shows names
+	NamesHidden    = "hidden"     // names are not even stored, see AddAnonymousVote
+)
+
+func validNameVisibility(v string) bool {
+	switch v {
+	case NamesPublic, NamesAdminOnly, NamesHidden:
+		return true
+	}
+	return false
+}
+
+// Anonymous reports whether participant pages must hide individual votes.
+func (p *Poll) Anonymous() bool {
+	return p.NameVisibility == NamesAdminOnly || p.NameVisibility == NamesHidden
+}
+
+// AddAnonymousVote records a vote without a voter name, for polls using
+// NamesHidden. The vote is stored under a random placeholder so the admin can
+// still edit or remove it. It returns the placeholder.
+func (s *Service) AddAnonymousVote(ctx context.Context, pollID string, responses map[string]string) (string, error) {
+	id, err := generateID()
+	if err != nil {
+		return "", fmt.Errorf("generate vote name: %w", err)
+	}
+	name := "anon-" + id[:10]
+	return name, s.AddVote(ctx, pollID, name, responses)
+}
@@ -0,0 +1,259 @@
+package poll
+
+import (
+	"container/list"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats is a point-in-time snapshot of a CachedRepository's counters.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+type cacheEntry struct {
+	poll    *Poll
+	expires time.Time
+}
+
+// CachedRepository is a read-through Repository decorator that keeps up to
+// size recently read polls in memory. Every mutation evicts the affected poll
+// once it has been forwarded, so a re-read after a vote sees the new state.
+// Callers get their own copy of a cached poll and may modify it. Values
+// computed from a cached poll with Derive, such as its totals, are kept with
+// it and dropped together with it.
+//
+// Mutations made by another process, such as a second server on the same
+// database, are not seen: the cache serves the old state until the entry
+// expires after ttl, or with a ttl of 0 until it is evicted or the server
+// restarts. The command line tools only add polls, which are not cached
+// while missing, or change admin links, whose lookups bypass the cache.
+//
+// Lookups by admin hash are not cached: admin links can be replaced from the
+// command line while the server runs, and a revoked link must stop working
+// at once rather than when the cached poll expires.
+type CachedRepository struct {
+	inner Repository
+	size  int
+	ttl   time.Duration // 0 means entries only leave through eviction
+
+	mu   sync.Mutex
+	lru  *list.List               // front = most recently used, values are *cacheEntry
+	byID map[string]*list.Element // public ID -> entry
+
+	// gen is bumped on every invalidation so that a read which raced with a
+	// mutation does not repopulate the cache with the pre-mutation poll.
+	gen uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	now func() time.Time
+}
+
+// NewCachedRepository wraps inner with a bounded LRU cache holding at most
+// size polls, each for at most ttl (0 disables expiry).
+func NewCachedRepository(inner Repository, size int, ttl time.Duration) *CachedRepository {
+	return &CachedRepository{
+		inner: inner,
+		size:  size,
+		ttl:   ttl,
+		lru:   list.New(),
+		byID:  make(map[string]*list.Element),
+		now:   time.Now,
+	}
+}
+
+// Stats returns the current hit/miss counters and number of cached polls.
+func (r *CachedRepository) Stats() CacheStats {
+	r.mu.Lock()
+	entries := r.lru.Len()
+	r.mu.Unlock()
+	return CacheStats{
+		Hits:    r.hits.Load(),
+		Misses:  r.misses.Load(),
+		Entries: entries,
+	}
+}
+
+func (r *CachedRepository) Create(ctx context.Context, p *Poll) error {
+	return r.inner.Create(ctx, p)
+}
+
+func (r *CachedRepository) GetByPublicID(ctx context.Context, publicID string) (*Poll, error) {
+	p, gen, ok := r.lookup(publicID)
+	if ok {
+		return clonePoll(p), nil
+	}
+	p, err := r.inner.GetByPublicID(ctx, publicID)
+	if err != nil || p == nil {
+		return p, err
+	}
+	// The copy returned here and every copy handed out from the cache share
+	// one set of derived values, which belongs to this read of the poll.
+	p.derived = &derived{values: make(map[string]any)}
+	r.store(clonePoll(p), gen)
+	return p, nil
+}
+
+func (r *CachedRepository) GetByAdminHash(ctx context.Context, adminHash string) (*Poll, error) {
+	return r.inner.GetByAdminHash(ctx, adminHash)
+}
+
+func (r *CachedRepository) SetAdminHash(ctx context.Context, pollID string, adminHash string) error {
+	defer r.Invalidate(pollID)
+	return r.inner.SetAdminHash(ctx, pollID, adminHash)
+}
+
+func (r *CachedRepository) SetClosedAt(ctx context.Context, pollID string, closedAt time.Time) error {
+	defer r.Invalidate(pollID)
+	return r.inner.SetClosedAt(ctx, pollID, closedAt)
+}
+
+func (r *CachedRepository) AddVote(ctx context.Context, pollID string, vote Vote) error {
+	defer r.Invalidate(pollID)
+	return r.inner.AddVote(ctx, pollID, vote)
+}
+
+func (r *CachedRepository) RemoveVote(ctx context.Context, pollID string, voterName string) error {
+	defer r.Invalidate(pollID)
+	return r.inner.RemoveVote(ctx, pollID, voterName)
+}
+
+func (r *CachedRepository) Delete(ctx context.Context, pollID string) error {
+	defer r.Invalidate(pollID)
+	return r.inner.Delete(ctx, pollID)
+}
+
+func (r *CachedRepository) UpdateVote(ctx context.Context, pollID string, oldName string, vote Vote) error {
+	defer r.Invalidate(pollID)
+	return r.inner.UpdateVote(ctx, pollID, oldName, vote)
+}
+
+func (r *CachedRepository) ListExpired(ctx context.Context, cutoff time.Time, basis string, limit int) ([]PollSummary, error) {
+	return r.inner.ListExpired(ctx, cutoff, basis, limit)
+}
+
+func (r *CachedRepository) DeleteMany(ctx context.Context, pollIDs []string) (int, error) {
+	defer func() {
+		for _, id := range pollIDs {
+			r.Invalidate(id)
+		}
+	}()
+	return r.inner.DeleteMany(ctx, pollIDs)
+}
+
+func (r *CachedRepository) ListIDs(ctx context.Context) ([]string, error) {
+	return r.inner.ListIDs(ctx)
+}
+
+func (r *CachedRepository) ListSummaries(ctx context.Context, query string, offset, limit int) ([]PollSummary, error) {
+	return r.inner.ListSummaries(ctx, query, offset, limit)
+}
+
+// Invalidate drops the cached copy of the poll with the given public ID, if any.
+func (r *CachedRepository) Invalidate(pollID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gen++
+	if el, ok := r.byID[pollID]; ok {
+		r.remove(el)
+	}
+}
+
+// lookup returns a live cached poll by public ID and records a hit or miss.
+// On a miss it also returns the invalidation generation to pass to store.
+func (r *CachedRepository) lookup(publicID string) (*Poll, uint64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	el, ok := r.byID[publicID]
+	if !ok {
+		r.misses.Add(1)
+		return nil, r.gen, false
+	}
+	e := el.Value.(*cacheEntry)
+	if r.ttl > 0 && r.now().After(e.expires) {
+		r.remove(el)
+		r.misses.Add(1)
+		return nil, r.gen, false
+	}
+	r.lru.MoveToFront(el)
+	r.hits.Add(1)
+	return e.poll, r.gen, true
+}
+
+// store inserts p, replacing any previous entry and evicting the least
+// recently used poll when the cache is full. The poll is dropped if any
+// invalidation happened since the lookup that returned gen.
+func (r *CachedRepository) store(p *Poll, gen uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if gen != r.gen {
+		return
+	}
+	if el, ok := r.byID[p.ID]; ok {
+		r.remove(el)
+	}
+	for r.lru.Len() >= r.size && r.lru.Len() > 0 {
+		r.remove(r.lru.Back())
+	}
+	r.byID[p.ID] = r.lru.PushFront(&cacheEntry{poll: p, expires: r.now().Add(r.ttl)})
+}
+
+// derived holds the values computed with Derive from one read of a poll.
+type derived struct {
+	mu     sync.Mutex
+	values map[string]any
+}
+
+// Derive returns compute(), which must depend only on p and on what key
+// encodes, such as the viewer's language. For a poll read through a
+// CachedRepository it runs once per key until the poll is invalidated or
+// expires, and every copy of the cached poll gets the same result, which
+// callers must not modify. For other polls compute runs every time. Callers
+// that modify their copy of a poll must not pass it to Derive.
+func Derive[T any](p *Poll, key string, compute func() T) T {
+	d := p.derived
+	if d == nil {
+		return compute()
+	}
+	d.mu.Lock()
+	v, ok := d.values[key]
+	d.mu.Unlock()
+	if ok {
+		return v.(T)
+	}
+	computed := compute()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if v, ok := d.values[key]; ok { // computed concurrently
+		return v.(T)
+	}
+	d.values[key] = computed
+	return computed
+}
+
+// clonePoll returns a deep copy of p, so that a caller modifying its poll
+// cannot change the cached one.
+func clonePoll(p *Poll) *Poll {
+	c := *p
+	c.Options = slices.Clone(p.Options)
+	c.Votes = make([]Vote, len(p.Votes))
+	for i, v := range p.Votes {
+		v.Responses = maps.Clone(v.Responses)
+		c.Votes[i] = v
+	}
+	return &c
+}
+
+// remove unlinks el from the LRU list and the index. Callers hold r.mu.
+func (r *CachedRepository) remove(el *list.Element) {
+	e := r.lru.Remove(el).(*cacheEntry)
+	delete(r.byID, e.poll.ID)
+}
@@ -0,0 +1,241 @@
+package poll
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingRepository records how many reads reach the wrapped repository.
+type countingRepository struct {
+	Repository
+	reads int
+}
+
+func (r *countingRepository) GetByPublicID(ctx context.Context, publicID string) (*Poll, error) {
+	r.reads++
+	return r.Repository.GetByPublicID(ctx, publicID)
+}
+
+func (r *countingRepository) GetByAdminHash(ctx context.Context, adminHash string) (*Poll, error) {
+	r.reads++
+	return r.Repository.GetByAdminHash(ctx, adminHash)
+}
+
+func newCachedTestService(size int, ttl time.Duration) (*Service, *CachedRepository, *countingRepository) {
+	inner := &countingRepository{Repository: NewMemoryRepository()}
+	cache := NewCachedRepository(inner, size, ttl)
+	return NewService(cache), cache, inner
+}
+
+func TestCachedRepositoryHit(t *testing.T) {
+	svc, cache, inner := newCachedTestService(10, 0)
+	p, _ := svc.Create(context.Background(), "Dinner", "", "yn", []string{"Mon"}, Settings{})
+
+	for i := 0; i < 3; i++ {
+		got, err := svc.Get(context.Background(), p.ID)
+		if err != nil || got == nil {
+			t.Fatalf("get: %v, %v", got, err)
+		}
+	}
+	if inner.reads != 1 {
+		t.Errorf("inner reads = %d, want 1", inner.reads)
+	}
+	st := cache.Stats()
+	if st.Hits != 2 || st.Misses != 1 || st.Entries != 1 {
+		t.Errorf("stats = %+v, want 2 hits, 1 miss, 1 entry", st)
+	}
+}
+
+func TestCachedRepositoryReturnsCopies(t *testing.T) {
+	svc, _, _ := newCachedTestService(10, 0)
+	p, _ := svc.Create(context.Background(), "Dinner", "", "yn", []string{"Mon"}, Settings{})
+	if err := svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 { // once from the database, once from the cache
+		got, _ := svc.Get(context.Background(), p.ID)
+		got.Title = "Changed"
+		got.Options[0] = "Tue"
+		got.Votes[0].Responses["Mon"] = "no"
+		got.Votes = append(got.Votes, Vote{Name: "Mallory"})
+	}
+
+	got, _ := svc.Get(context.Background(), p.ID)
+	if got.Title != "Dinner" || got.Options[0] != "Mon" || len(got.Votes) != 1 || got.Votes[0].Responses["Mon"] != "yes" {
+		t.Errorf("cached poll was modified through a returned copy: %+v", got)
+	}
+}
+
+func TestCachedRepositoryDoesNotCacheAdminLookups(t *testing.T) {
+	svc, _, inner := newCachedTestService(10, 0)
+	p, _ := svc.Create(context.Background(), "Dinner", "", "yn", []string{"Mon"}, Settings{})
+	for range 2 {
+		if got, err := svc.GetByAdminID(context.Background(), p.AdminID); err != nil || got == nil {
+			t.Fatalf("get by admin id: %v, %v", got, err)
+		}
+	}
+	if inner.reads != 2 {
+		t.Errorf("inner reads = %d, want 2", inner.reads)
+	}
+
+	// A link replaced behind the cache's back, as by "meetkat admin-link"
+	// while the server runs, stops working at once.
+	if err := inner.SetAdminHash(context.Background(), p.ID, "replaced"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := svc.GetByAdminID(context.Background(), p.AdminID); got != nil {
+		t.Error("replaced admin link still works")
+	}
+}
+
+func TestCachedRepositoryInvalidatesOnMutation(t *testing.T) {
+	svc, cache, inner := newCachedTestService(10, 0)
+	p, _ := svc.Create(context.Background(), "Offsite", "", "yn", []string{"Mon", "Tue"}, Settings{})
+
+	_, _ = svc.Get(context.Background(), p.ID)
+	if err := svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes"}); err != nil {
+		t.Fatalf("add vote: %v", err)
+	}
+	if cache.Stats().Entries != 0 {
+		t.Fatal("expected AddVote to evict the poll")
+	}
+	_, _ = svc.Get(context.Background(), p.ID)
+	if err := svc.UpdateVote(context.Background(), p.ID, "Alice", "Alicia", map[string]string{"Mon": "no"}); err != nil {
+		t.Fatalf("update vote: %v", err)
+	}
+	if cache.Stats().Entries != 0 {
+		t.Fatal("expected UpdateVote to evict the poll")
+	}
+	_, _ = svc.Get(context.Background(), p.ID)
+	if err := svc.RemoveVote(context.Background(), p.ID, "Alicia"); err != nil {
+		t.Fatalf("remove vote: %v", err)
+	}
+	if cache.Stats().Entries != 0 {
+		t.Fatal("expected RemoveVote to evict the poll")
+	}
+	_, _ = svc.GetByAdminID(context.Background(), p.AdminID)
+	if _, err := svc.ResetAdminID(context.Background(), p.ID); err != nil {
+		t.Fatalf("reset admin id: %v", err)
+	}
+	if got, _ := svc.GetByAdminID(context.Background(), p.AdminID); got != nil {
+		t.Fatal("expected the old admin ID not to be served from cache")
+	}
+	_, _ = svc.Get(context.Background(), p.ID)
+	if err := svc.SetClosed(context.Background(), p.ID, true); err != nil {
+		t.Fatalf("set closed: %v", err)
+	}
+	if cache.Stats().Entries != 0 {
+		t.Fatal("expected SetClosed to evict the poll")
+	}
+	_, _ = svc.Get(context.Background(), p.ID)
+	if err := svc.Delete(context.Background(), p.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	got, err := svc.Get(context.Background(), p.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got != nil {
+		t.Fatal("expected deleted poll not to be served from cache")
+	}
+	if inner.reads != 8 {
+		t.Errorf("inner reads = %d, want 8", inner.reads)
+	}
+}
+
+func TestCachedRepositoryEvictsLeastRecentlyUsed(t *testing.T) {
+	svc, cache, inner := newCachedTestService(2, 0)
+	a, _ := svc.Create(context.Background(), "A", "", "yn", []string{"x"}, Settings{})
+	b, _ := svc.Create(context.Background(), "B", "", "yn", []string{"x"}, Settings{})
+	c, _ := svc.Create(context.Background(), "C", "", "yn", []string{"x"}, Settings{})
+
+	_, _ = svc.Get(context.Background(), a.ID)
+	_, _ = svc.Get(context.Background(), b.ID)
+	_, _ = svc.Get(context.Background(), a.ID) // a becomes most recently used
+	_, _ = svc.Get(context.Background(), c.ID) // evicts b
+
+	if n := cache.Stats().Entries; n != 2 {
+		t.Fatalf("entries = %d, want 2", n)
+	}
+	reads := inner.reads
+	_, _ = svc.Get(context.Background(), a.ID)
+	if inner.reads != reads {
+		t.Error("expected a to still be cached")
+	}
+	_, _ = svc.Get(context.Background(), b.ID)
+	if inner.reads != reads+1 {
+		t.Error("expected b to have been evicted")
+	}
+}
+
+func TestCachedRepositoryTTL(t *testing.T) {
+	svc, cache, inner := newCachedTestService(10, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	p, _ := svc.Create(context.Background(), "Lunch", "", "yn", []string{"Wed"}, Settings{})
+
+	_, _ = svc.Get(context.Background(), p.ID)
+	now = now.Add(30 * time.Second)
+	_, _ = svc.Get(context.Background(), p.ID)
+	if inner.reads != 1 {
+		t.Fatalf("inner reads = %d, want 1 before expiry", inner.reads)
+	}
+	now = now.Add(time.Minute)
+	_, _ = svc.Get(context.Background(), p.ID)
+	if inner.reads != 2 {
+		t.Errorf("inner reads = %d, want 2 after expiry", inner.reads)
+	}
+}
+
+func TestCachedRepositoryDoesNotCacheMisses(t *testing.T) {
+	svc, cache, _ := newCachedTestService(10, 0)
+	got, err := svc.Get(context.Background(), "doesnotexist")
+	if err != nil || got != nil {
+		t.Fatalf("expected not found, got %v, %v", got, err)
+	}
+	if n := cache.Stats().Entries; n != 0 {
+		t.Errorf("entries = %d, want 0", n)
+	}
+}
+
+func TestDeriveIsKeptWithTheCachedPoll(t *testing.T) {
+	svc, _, _ := newCachedTestService(10, 0)
+	ctx := context.Background()
+	p, _ := svc.Create(ctx, "Dinner", "", "yn", []string{"Mon"}, Settings{})
+
+	computed := 0
+	yes := func(p *Poll) int {
+		return Derive(p, "yes", func() int {
+			computed++
+			return Totals(p)["Mon"].Yes
+		})
+	}
+
+	for range 3 { // one database read, then two cache hits
+		got, _ := svc.Get(ctx, p.ID)
+		if n := yes(got); n != 0 {
+			t.Fatalf("yes = %d, want 0", n)
+		}
+	}
+	if computed != 1 {
+		t.Errorf("computed %d times, want once for the cached poll", computed)
+	}
+
+	if err := svc.AddVote(ctx, p.ID, "Alice", map[string]string{"Mon": "yes"}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := svc.Get(ctx, p.ID)
+	if n := yes(got); n != 1 || computed != 2 {
+		t.Errorf("after a vote: yes = %d, computed %d times; want 1 and 2", n, computed)
+	}
+
+	plain := &Poll{ID: p.ID, Options: []string{"Mon"}}
+	yes(plain)
+	yes(plain)
+	if computed != 4 {
+		t.Errorf("computed %d times, want every call for a poll not read through the cache", computed)
+	}
+}
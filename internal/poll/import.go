@@ -0,0 +1,130 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Conflict policies for Service.Import, applied when the public or admin ID
+// of an imported poll is already taken on this instance.
+const (
+	ImportSkip   = "skip"   // keep the existing poll and ignore the imported one
+	ImportRename = "rename" // import under freshly generated IDs
+	ImportFail   = "fail"   // abort with ErrIDConflict
+)
+
+// ErrIDConflict is returned by Import under ImportFail when an ID is taken.
+var ErrIDConflict = errors.New("poll id already exists")
+
+// ListIDs returns the public IDs of all polls, oldest first.
+func (s *Service) ListIDs(ctx context.Context) (ids []string, err error) {
+	ctx, span := startSpan(ctx, "ListIDs")
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.ListIDs(ctx)
+}
+
+// Import stores a poll exported from another instance, preserving its IDs,
+// timestamps, and votes. A poll carrying its AdminID is stored under the hash
+// of that ID with this instance's key; otherwise its AdminHash is kept, which
+// only matches the admin link if both instances share the key. When its IDs
+// collide with an existing poll, onConflict decides what happens. It returns
+// the stored poll, or nil if the poll was skipped.
+func (s *Service) Import(ctx context.Context, p *Poll, onConflict string) (stored *Poll, err error) {
+	ctx, span := startSpan(ctx, "Import")
+	defer func() { endSpan(span, err) }()
+
+	if err := s.validateImport(p); err != nil {
+		return nil, err
+	}
+	if p.AdminID != "" {
+		hashed := *p
+		hashed.AdminHash = HashAdminID(s.adminKey, p.AdminID)
+		p = &hashed
+	}
+
+	taken, err := s.idsTaken(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		switch onConflict {
+		case ImportSkip:
+			return nil, nil
+		case ImportRename:
+			renamed := *p
+			if renamed.ID, err = generateID(); err != nil {
+				return nil, fmt.Errorf("generate poll id: %w", err)
+			}
+			if renamed.AdminID, err = generateID(); err != nil {
+				return nil, fmt.Errorf("generate admin id: %w", err)
+			}
+			renamed.AdminHash = HashAdminID(s.adminKey, renamed.AdminID)
+			p = &renamed
+		case ImportFail:
+			return nil, fmt.Errorf("import poll %s: %w", p.ID, ErrIDConflict)
+		default:
+			return nil, fmt.Errorf("unknown conflict policy %q", onConflict)
+		}
+	}
+
+	// As in Create, the admin ID itself never reaches the repository.
+	withoutAdminID := *p
+	withoutAdminID.AdminID = ""
+	if err := s.repo.Create(ctx, &withoutAdminID); err != nil {
+		return nil, fmt.Errorf("import poll %s: %w", p.ID, err)
+	}
+	return p, nil
+}
+
+// idsTaken reports whether p's public ID or admin hash is already in use.
+func (s *Service) idsTaken(ctx context.Context, p *Poll) (bool, error) {
+	existing, err := s.repo.GetByPublicID(ctx, p.ID)
+	if err != nil {
+		return false, fmt.Errorf("check poll id: %w", err)
+	}
+	if existing != nil {
+		return true, nil
+	}
+	existing, err = s.repo.GetByAdminHash(ctx, p.AdminHash)
+	if err != nil {
+		return false, fmt.Errorf("check admin id: %w", err)
+	}
+	return existing != nil, nil
+}
+
+// validateImport applies the same limits as Create and AddVote to a poll
+// that did not go through them on this instance.
+func (s *Service) validateImport(p *Poll) error {
+	if p.ID == "" || (p.AdminID == "" && p.AdminHash == "") {
+		return errors.New("poll id and admin id or hash must not be empty")
+	}
+	if p.Title == "" {
+		return fmt.Errorf("poll %s: title must not be empty", p.ID)
+	}
+	if len(p.Title) > s.limits.MaxTitleLen {
+		return fmt.Errorf("poll %s: title exceeds %d characters", p.ID, s.limits.MaxTitleLen)
+	}
+	if len(p.Description) > s.limits.MaxDescriptionLen {
+		return fmt.Errorf("poll %s: description exceeds %d characters", p.ID, s.limits.MaxDescriptionLen)
+	}
+	if len(p.Options) == 0 || len(p.Options) > s.limits.MaxOptions {
+		return fmt.Errorf("poll %s: needs between 1 and %d options", p.ID, s.limits.MaxOptions)
+	}
+	if p.AnswerMode != AnswerModeYN && p.AnswerMode != AnswerModeYMN {
+		return fmt.Errorf("poll %s: unknown answer mode %q", p.ID, p.AnswerMode)
+	}
+	if p.ResultsVisibility != "" && !validResultsVisibility(p.ResultsVisibility) {
+		return fmt.Errorf("poll %s: unknown results visibility %q", p.ID, p.ResultsVisibility)
+	}
+	if p.NameVisibility != "" && !validNameVisibility(p.NameVisibility) {
+		return fmt.Errorf("poll %s: unknown name visibility %q", p.ID, p.NameVisibility)
+	}
+	for _, v := range p.Votes {
+		if v.Name == "" || len(v.Name) > s.limits.MaxNameLen {
+			return fmt.Errorf("poll %s: invalid voter name %q", p.ID, v.Name)
+		}
+	}
+	return nil
+}
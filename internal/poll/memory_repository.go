@@ -1,14 +1,19 @@
 package poll
 
 import (
+	"context"
 	"errors"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 // MemoryRepository is an in-memory implementation of Repository.
 type MemoryRepository struct {
 	mu    sync.Mutex
 	polls map[string]*Poll
+	order []string // public IDs in creation order
 }
 
 func NewMemoryRepository() *MemoryRepository {
@@ -17,14 +22,17 @@ func NewMemoryRepository() *MemoryRepository {
 	}
 }
 
-func (r *MemoryRepository) Create(p *Poll) error {
+func (r *MemoryRepository) Create(_ context.Context, p *Poll) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if _, ok := r.polls[p.ID]; !ok {
+		r.order = append(r.order, p.ID)
+	}
 	r.polls[p.ID] = p
 	return nil
 }
 
-func (r *MemoryRepository) GetByPublicID(publicID string) (*Poll, error) {
+func (r *MemoryRepository) GetByPublicID(_ context.Context, publicID string) (*Poll, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	p, ok := r.polls[publicID]
@@ -34,18 +42,40 @@ func (r *MemoryRepository) GetByPublicID(publicID string) (*Poll, error) {
 	return p, nil
 }
 
-func (r *MemoryRepository) GetByAdminID(adminID string) (*Poll, error) {
+func (r *MemoryRepository) GetByAdminHash(_ context.Context, adminHash string) (*Poll, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	for _, p := range r.polls {
-		if p.AdminID == adminID {
+		if p.AdminHash == adminHash {
 			return p, nil
 		}
 	}
 	return nil, nil
 }
 
-func (r *MemoryRepository) AddVote(pollID string, vote Vote) error {
+func (r *MemoryRepository) SetAdminHash(_ context.Context, pollID string, adminHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.polls[pollID]
+	if !ok {
+		return errors.New("poll not found")
+	}
+	p.AdminHash = adminHash
+	return nil
+}
+
+func (r *MemoryRepository) SetClosedAt(_ context.Context, pollID string, closedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.polls[pollID]
+	if !ok {
+		return errors.New("poll not found")
+	}
+	p.ClosedAt = closedAt
+	return nil
+}
+
+func (r *MemoryRepository) AddVote(_ context.Context, pollID string, vote Vote) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	p, ok := r.polls[pollID]
@@ -56,7 +86,7 @@ func (r *MemoryRepository) AddVote(pollID string, vote Vote) error {
 	return nil
 }
 
-func (r *MemoryRepository) RemoveVote(pollID string, voterName string) error {
+func (r *MemoryRepository) RemoveVote(_ context.Context, pollID string, voterName string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	p, ok := r.polls[pollID]
@@ -72,7 +102,7 @@ func (r *MemoryRepository) RemoveVote(pollID string, voterName string) error {
 	return errors.New("vote not found")
 }
 
-func (r *MemoryRepository) Delete(pollID string) error {
+func (r *MemoryRepository) Delete(_ context.Context, pollID string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if _, ok := r.polls[pollID]; !ok {
@@ -82,7 +112,7 @@ func (r *MemoryRepository) Delete(pollID string) error {
 	return nil
 }
 
-func (r *MemoryRepository) UpdateVote(pollID string, oldName string, vote Vote) error {
+func (r *MemoryRepository) UpdateVote(_ context.Context, pollID string, oldName string, vote Vote) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	p, ok := r.polls[pollID]
@@ -91,9 +121,91 @@ func (r *MemoryRepository) UpdateVote(pollID string, oldName string, vote Vote)
 	}
 	for i, v := range p.Votes {
 		if v.Name == oldName {
+			vote.VotedAt = v.VotedAt
 			p.Votes[i] = vote
 			return nil
 		}
 	}
 	return errors.New("vote not found")
 }
+
+func (r *MemoryRepository) ListExpired(_ context.Context, cutoff time.Time, basis string, limit int) ([]PollSummary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var expired []PollSummary
+	for _, p := range r.polls {
+		s := summarize(p)
+		if s.expired(basis, cutoff) {
+			expired = append(expired, s)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].retentionClock(basis).Before(expired[j].retentionClock(basis))
+	})
+	if limit > 0 && len(expired) > limit {
+		expired = expired[:limit]
+	}
+	return expired, nil
+}
+
+func (r *MemoryRepository) DeleteMany(_ context.Context, pollIDs []string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, id := range pollIDs {
+		if _, ok := r.polls[id]; ok {
+			delete(r.polls, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *MemoryRepository) ListIDs(_ context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.polls))
+	for _, id := range r.order {
+		if _, ok := r.polls[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *MemoryRepository) ListSummaries(_ context.Context, query string, offset, limit int) ([]PollSummary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	query = strings.ToLower(query)
+	var polls []PollSummary
+	for i := len(r.order) - 1; i >= 0; i-- {
+		p, ok := r.polls[r.order[i]]
+		if !ok || !strings.Contains(strings.ToLower(p.Title), query) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if len(polls) == limit {
+			break
+		}
+		polls = append(polls, summarize(p))
+	}
+	return polls, nil
+}
+
+// summarize builds a PollSummary from a fully loaded poll.
+func summarize(p *Poll) PollSummary {
+	s := PollSummary{ID: p.ID, Title: p.Title, CreatedAt: p.CreatedAt, ClosedAt: p.ClosedAt, Votes: len(p.Votes)}
+	for _, v := range p.Votes {
+		last := v.VotedAt
+		if v.EditedAt.After(last) {
+			last = v.EditedAt
+		}
+		if last.After(s.LastVoteAt) {
+			s.LastVoteAt = last
+		}
+	}
+	return s
+}
@@ -0,0 +1,13 @@
+package poll
+
+import "context"
+
+// ListPolls returns summaries of up to limit polls whose title contains query
+// (ignoring case; all polls when query is empty), newest first, after
+// skipping offset matches. It backs the operator console.
+func (s *Service) ListPolls(ctx context.Context, query string, offset, limit int) (polls []PollSummary, err error) {
+	ctx, span := startSpan(ctx, "ListPolls")
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.ListSummaries(ctx, query, offset, limit)
+}
@@ -1,28 +1,65 @@
 package poll
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base32"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Vote struct {
 	Name      string
 	Responses map[string]string // key = option string, value = "yes", "no", "maybe", or ""
+	VotedAt   time.Time
+	EditedAt  time.Time // zero if the vote was never edited
 }
 
 type Poll struct {
-	ID          string
+	ID string
+	// AdminID is the secret admin token. Only its keyed hash, AdminHash, is
+	// stored, so AdminID is empty unless the poll was just created, looked
+	// up by admin ID, or given a new admin ID.
 	AdminID     string
+	AdminHash   string
 	Title       string
 	Description string
 	AnswerMode  string // "yn" (yes/no) or "ymn" (yes/maybe/no); default "yn"
 	Options     []string
 	Votes       []Vote
 	CreatedAt   time.Time
+	// PasswordHash is the bcrypt hash of the poll password, or empty if the
+	// poll is not password protected.
+	PasswordHash string
+	// ResultsVisibility is one of the Results* policies; empty means
+	// ResultsAlways.
+	ResultsVisibility string
+	// NameVisibility is one of the Names* policies; empty means NamesPublic.
+	NameVisibility string
+	ClosedAt       time.Time // zero while the poll takes votes
+
+	// derived is set on polls read through a CachedRepository; see Derive.
+	derived *derived
+}
+
+// Settings are the optional choices made when creating a poll.
+type Settings struct {
+	// Password, if set, has to be entered before the poll can be viewed or
+	// voted on. Only its hash is stored.
+	Password string
+	// ResultsVisibility is one of the Results* policies; anything else
+	// means ResultsAlways.
+	ResultsVisibility string
+	// NameVisibility is one of the Names* policies; anything else means
+	// NamesPublic.
+	NameVisibility string
 }
 
 type OptionTotal struct {
@@ -31,11 +68,51 @@ type OptionTotal struct {
 }
 
 type Service struct {
-	repo Repository
+	repo     Repository
+	limits   Limits
+	adminKey []byte
 }
 
 func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+	return &Service{repo: repo, limits: DefaultLimits()}
+}
+
+// Limits bounds the size of user input accepted by the service.
+type Limits struct {
+	MaxTitleLen       int
+	MaxDescriptionLen int
+	MaxNameLen        int
+	MaxOptions        int
+}
+
+// DefaultLimits returns the limits used unless SetLimits is called.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxTitleLen:       MaxTitleLen,
+		MaxDescriptionLen: MaxDescriptionLen,
+		MaxNameLen:        MaxNameLen,
+		MaxOptions:        MaxOptions,
+	}
+}
+
+// SetLimits replaces the input limits. It must be called before the service
+// is used.
+func (s *Service) SetLimits(l Limits) {
+	s.limits = l
+}
+
+// SetAdminKey sets the key admin IDs are hashed with. Changing it breaks
+// every existing admin link, so it must stay the same for the lifetime of
+// the database. It must be called before the service is used.
+func (s *Service) SetAdminKey(key []byte) {
+	s.adminKey = key
+}
+
+// HashAdminID returns the keyed hash stored in place of an admin ID.
+func HashAdminID(key []byte, adminID string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(adminID))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 // generateID returns a 26-character lowercase base32 string with 128-bit entropy.
@@ -54,25 +131,43 @@ const (
 	AnswerModeYN  = "yn"
 	AnswerModeYMN = "ymn"
 
+	// Default input limits; see Limits.
 	MaxTitleLen       = 200
 	MaxDescriptionLen = 2000
 	MaxNameLen        = 100
 	MaxOptions        = 60
+
+	// MaxPasswordLen is the longest password bcrypt can hash, in bytes.
+	MaxPasswordLen = 72
 )
 
-func (s *Service) Create(title, description, answerMode string, options []string) (*Poll, error) {
-	if len(title) > MaxTitleLen {
-		return nil, fmt.Errorf("title exceeds %d characters", MaxTitleLen)
+func (s *Service) Create(ctx context.Context, title, description, answerMode string, options []string, settings Settings) (p *Poll, err error) {
+	ctx, span := startSpan(ctx, "Create")
+	defer func() { endSpan(span, err) }()
+
+	if len(title) > s.limits.MaxTitleLen {
+		return nil, fmt.Errorf("title exceeds %d characters", s.limits.MaxTitleLen)
 	}
-	if len(description) > MaxDescriptionLen {
-		return nil, fmt.Errorf("description exceeds %d characters", MaxDescriptionLen)
+	if len(description) > s.limits.MaxDescriptionLen {
+		return nil, fmt.Errorf("description exceeds %d characters", s.limits.MaxDescriptionLen)
 	}
-	if len(options) > MaxOptions {
-		return nil, fmt.Errorf("too many options (max %d)", MaxOptions)
+	if len(options) > s.limits.MaxOptions {
+		return nil, fmt.Errorf("too many options (max %d)", s.limits.MaxOptions)
+	}
+	if len(settings.Password) > MaxPasswordLen {
+		return nil, fmt.Errorf("password exceeds %d bytes", MaxPasswordLen)
 	}
 	if answerMode != AnswerModeYN && answerMode != AnswerModeYMN {
 		answerMode = AnswerModeYN
 	}
+	resultsVisibility := settings.ResultsVisibility
+	if !validResultsVisibility(resultsVisibility) {
+		resultsVisibility = ResultsAlways
+	}
+	nameVisibility := settings.NameVisibility
+	if !validNameVisibility(nameVisibility) {
+		nameVisibility = NamesPublic
+	}
 
 	id, err := generateID()
 	if err != nil {
@@ -83,55 +178,113 @@ func (s *Service) Create(title, description, answerMode string, options []string
 		return nil, fmt.Errorf("generate admin id: %w", err)
 	}
 
-	p := &Poll{
+	p = &Poll{
 		ID:          id,
-		AdminID:     adminID,
+		AdminHash:   HashAdminID(s.adminKey, adminID),
 		Title:       title,
 		Description: description,
 		AnswerMode:  answerMode,
 		Options:     options,
 		CreatedAt:   time.Now(),
+
+		ResultsVisibility: resultsVisibility,
+		NameVisibility:    nameVisibility,
 	}
-	if err := s.repo.Create(p); err != nil {
+	if settings.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(settings.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("hash password: %w", err)
+		}
+		p.PasswordHash = string(hash)
+	}
+	if err := s.repo.Create(ctx, p); err != nil {
 		return nil, fmt.Errorf("create poll: %w", err)
 	}
-	return p, nil
+	// The admin ID itself never reaches the repository, which may keep p.
+	created := *p
+	created.AdminID = adminID
+	return &created, nil
 }
 
-func (s *Service) Get(id string) (*Poll, error) {
-	return s.repo.GetByPublicID(id)
+func (s *Service) Get(ctx context.Context, id string) (p *Poll, err error) {
+	ctx, span := startSpan(ctx, "Get")
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.GetByPublicID(ctx, id)
 }
 
-func (s *Service) GetByAdminID(adminID string) (*Poll, error) {
-	return s.repo.GetByAdminID(adminID)
+func (s *Service) GetByAdminID(ctx context.Context, adminID string) (p *Poll, err error) {
+	ctx, span := startSpan(ctx, "GetByAdminID")
+	defer func() { endSpan(span, err) }()
+
+	p, err = s.repo.GetByAdminHash(ctx, HashAdminID(s.adminKey, adminID))
+	if err != nil || p == nil {
+		return nil, err
+	}
+	found := *p
+	found.AdminID = adminID
+	return &found, nil
 }
 
-func (s *Service) RemoveVote(pollID, voterName string) error {
-	return s.repo.RemoveVote(pollID, voterName)
+// ResetAdminID gives the poll a new admin ID and returns it. The previous
+// admin link stops working immediately.
+func (s *Service) ResetAdminID(ctx context.Context, pollID string) (adminID string, err error) {
+	ctx, span := startSpan(ctx, "ResetAdminID")
+	defer func() { endSpan(span, err) }()
+
+	adminID, err = generateID()
+	if err != nil {
+		return "", fmt.Errorf("generate admin id: %w", err)
+	}
+	if err := s.repo.SetAdminHash(ctx, pollID, HashAdminID(s.adminKey, adminID)); err != nil {
+		return "", err
+	}
+	return adminID, nil
 }
 
-func (s *Service) AddVote(pollID, name string, responses map[string]string) error {
+func (s *Service) RemoveVote(ctx context.Context, pollID, voterName string) (err error) {
+	ctx, span := startSpan(ctx, "RemoveVote")
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.RemoveVote(ctx, pollID, voterName)
+}
+
+func (s *Service) AddVote(ctx context.Context, pollID, name string, responses map[string]string) (err error) {
+	ctx, span := startSpan(ctx, "AddVote")
+	defer func() { endSpan(span, err) }()
+
 	if name == "" {
 		return errors.New("name must not be empty")
 	}
-	if len(name) > MaxNameLen {
-		return fmt.Errorf("name exceeds %d characters", MaxNameLen)
+	if len(name) > s.limits.MaxNameLen {
+		return fmt.Errorf("name exceeds %d characters", s.limits.MaxNameLen)
 	}
-	return s.repo.AddVote(pollID, Vote{Name: name, Responses: responses})
+	return s.repo.AddVote(ctx, pollID, Vote{Name: name, Responses: responses, VotedAt: time.Now()})
 }
 
-func (s *Service) Delete(pollID string) error {
-	return s.repo.Delete(pollID)
+func (s *Service) Delete(ctx context.Context, pollID string) (err error) {
+	ctx, span := startSpan(ctx, "Delete")
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.Delete(ctx, pollID)
 }
 
-func (s *Service) UpdateVote(pollID, oldName, newName string, responses map[string]string) error {
+func (s *Service) UpdateVote(ctx context.Context, pollID, oldName, newName string, responses map[string]string) (err error) {
+	ctx, span := startSpan(ctx, "UpdateVote")
+	defer func() { endSpan(span, err) }()
+
 	if newName == "" {
 		return errors.New("name must not be empty")
 	}
-	if len(newName) > MaxNameLen {
-		return fmt.Errorf("name exceeds %d characters", MaxNameLen)
+	if len(newName) > s.limits.MaxNameLen {
+		return fmt.Errorf("name exceeds %d characters", s.limits.MaxNameLen)
 	}
-	return s.repo.UpdateVote(pollID, oldName, Vote{Name: newName, Responses: responses})
+	return s.repo.UpdateVote(ctx, pollID, oldName, Vote{Name: newName, Responses: responses, EditedAt: time.Now()})
+}
+
+// CheckPassword reports whether password unlocks p. It is deliberately slow.
+func CheckPassword(p *Poll, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(p.PasswordHash), []byte(password)) == nil
 }
 
 func Totals(p *Poll) map[string]OptionTotal {
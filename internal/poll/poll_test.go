@@ -1,12 +1,15 @@
 package poll
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCreate(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
-	p, err := svc.Create("Dinner", "Pick your evening", "yn", []string{"Mon", "Tue"})
+	p, err := svc.Create(context.Background(), "Dinner", "Pick your evening", "yn", []string{"Mon", "Tue"}, Settings{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -42,7 +45,7 @@ func TestCreate(t *testing.T) {
 
 func TestCreateDefaultAnswerMode(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
-	p, err := svc.Create("Test", "", "invalid", []string{"A"})
+	p, err := svc.Create(context.Background(), "Test", "", "invalid", []string{"A"}, Settings{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -51,14 +54,36 @@ func TestCreateDefaultAnswerMode(t *testing.T) {
 	}
 }
 
+func TestSetLimits(t *testing.T) {
+	svc := NewService(NewMemoryRepository())
+	svc.SetLimits(Limits{MaxTitleLen: 5, MaxDescriptionLen: 10, MaxNameLen: 3, MaxOptions: 2})
+
+	if _, err := svc.Create(context.Background(), "Too long", "", "yn", []string{"A"}, Settings{}); err == nil {
+		t.Error("expected error for title over the configured limit")
+	}
+	if _, err := svc.Create(context.Background(), "Lunch", "", "yn", []string{"A", "B", "C"}, Settings{}); err == nil {
+		t.Error("expected error for too many options")
+	}
+	p, err := svc.Create(context.Background(), "Lunch", "", "yn", []string{"A", "B"}, Settings{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.AddVote(context.Background(), p.ID, "Alice", nil); err == nil {
+		t.Error("expected error for name over the configured limit")
+	}
+	if err := svc.AddVote(context.Background(), p.ID, "Al", nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestGet(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
-	created, err := svc.Create("Lunch", "", "yn", []string{"Wed"})
+	created, err := svc.Create(context.Background(), "Lunch", "", "yn", []string{"Wed"}, Settings{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	got, err := svc.Get(created.ID)
+	got, err := svc.Get(context.Background(), created.ID)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -72,7 +97,7 @@ func TestGet(t *testing.T) {
 
 func TestGetNotFound(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
-	got, err := svc.Get("doesnotexist")
+	got, err := svc.Get(context.Background(), "doesnotexist")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -83,17 +108,17 @@ func TestGetNotFound(t *testing.T) {
 
 func TestAddVote(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
-	p, err := svc.Create("Offsite", "", "yn", []string{"Mon", "Tue"})
+	p, err := svc.Create(context.Background(), "Offsite", "", "yn", []string{"Mon", "Tue"}, Settings{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	err = svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
+	err = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes", "Tue": "no"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote, got %d", len(got.Votes))
 	}
@@ -110,9 +135,9 @@ func TestAddVote(t *testing.T) {
 
 func TestAddVoteEmptyName(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
-	p, _ := svc.Create("Test", "", "yn", []string{"A"})
+	p, _ := svc.Create(context.Background(), "Test", "", "yn", []string{"A"}, Settings{})
 
-	err := svc.AddVote(p.ID, "", map[string]string{"A": "yes"})
+	err := svc.AddVote(context.Background(), p.ID, "", map[string]string{"A": "yes"})
 	if err == nil {
 		t.Fatal("expected error for empty name")
 	}
@@ -124,7 +149,7 @@ func TestAddVoteEmptyName(t *testing.T) {
 func TestAddVoteNonexistentPoll(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
 
-	err := svc.AddVote("nope", "Alice", map[string]string{})
+	err := svc.AddVote(context.Background(), "nope", "Alice", map[string]string{})
 	if err == nil {
 		t.Fatal("expected error for nonexistent poll")
 	}
@@ -183,12 +208,12 @@ func TestTotalsWithMaybe(t *testing.T) {
 
 func TestGetByAdminID(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
-	created, err := svc.Create("Meeting", "", "yn", []string{"Mon"})
+	created, err := svc.Create(context.Background(), "Meeting", "", "yn", []string{"Mon"}, Settings{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	got, err := svc.GetByAdminID(created.AdminID)
+	got, err := svc.GetByAdminID(context.Background(), created.AdminID)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -205,7 +230,7 @@ func TestGetByAdminID(t *testing.T) {
 
 func TestGetByAdminIDNotFound(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
-	got, err := svc.GetByAdminID("doesnotexist")
+	got, err := svc.GetByAdminID(context.Background(), "doesnotexist")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -214,21 +239,184 @@ func TestGetByAdminIDNotFound(t *testing.T) {
 	}
 }
 
+func TestCreateStoresOnlyAdminHash(t *testing.T) {
+	repo := NewMemoryRepository()
+	svc := NewService(repo)
+	svc.SetAdminKey([]byte("key one"))
+	created, err := svc.Create(context.Background(), "Meeting", "", "yn", []string{"Mon"}, Settings{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored, _ := repo.GetByPublicID(context.Background(), created.ID)
+	if stored.AdminID != "" {
+		t.Errorf("expected the admin ID not to be stored, got %q", stored.AdminID)
+	}
+	if want := HashAdminID([]byte("key one"), created.AdminID); stored.AdminHash != want {
+		t.Errorf("admin hash: got %q, want %q", stored.AdminHash, want)
+	}
+
+	other := NewService(repo)
+	other.SetAdminKey([]byte("key two"))
+	if got, _ := other.GetByAdminID(context.Background(), created.AdminID); got != nil {
+		t.Error("expected lookup with a different key to fail")
+	}
+}
+
+func TestResetAdminID(t *testing.T) {
+	svc := NewService(NewMemoryRepository())
+	created, _ := svc.Create(context.Background(), "Meeting", "", "yn", []string{"Mon"}, Settings{})
+
+	adminID, err := svc.ResetAdminID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if adminID == created.AdminID {
+		t.Fatal("expected a new admin ID")
+	}
+	if got, _ := svc.GetByAdminID(context.Background(), created.AdminID); got != nil {
+		t.Error("expected the old admin ID to stop working")
+	}
+	got, _ := svc.GetByAdminID(context.Background(), adminID)
+	if got == nil || got.ID != created.ID || got.AdminID != adminID {
+		t.Errorf("expected the new admin ID to find the poll, got %+v", got)
+	}
+
+	if _, err := svc.ResetAdminID(context.Background(), "doesnotexist"); err == nil {
+		t.Error("expected error for nonexistent poll")
+	}
+}
+
+func TestCreateWithPassword(t *testing.T) {
+	svc := NewService(NewMemoryRepository())
+	p, err := svc.Create(context.Background(), "Secret", "", "yn", []string{"Mon"}, Settings{Password: "hunter2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.PasswordHash == "" || p.PasswordHash == "hunter2" {
+		t.Fatalf("expected a password hash, got %q", p.PasswordHash)
+	}
+	if !CheckPassword(p, "hunter2") {
+		t.Error("expected the password to match")
+	}
+	if CheckPassword(p, "hunter3") {
+		t.Error("expected a wrong password not to match")
+	}
+
+	open, _ := svc.Create(context.Background(), "Open", "", "yn", []string{"Mon"}, Settings{})
+	if open.PasswordHash != "" {
+		t.Errorf("expected no password hash, got %q", open.PasswordHash)
+	}
+
+	long := strings.Repeat("x", MaxPasswordLen+1)
+	if _, err := svc.Create(context.Background(), "Long", "", "yn", []string{"Mon"}, Settings{Password: long}); err == nil {
+		t.Error("expected error for a password over the bcrypt limit")
+	}
+}
+
+func TestResultsVisible(t *testing.T) {
+	closed := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		visibility string
+		closedAt   time.Time
+		hasVoted   bool
+		want       bool
+	}{
+		{"", time.Time{}, false, true},
+		{ResultsAlways, time.Time{}, false, true},
+		{ResultsAfterVote, time.Time{}, false, false},
+		{ResultsAfterVote, time.Time{}, true, true},
+		{ResultsAfterVote, closed, false, true},
+		{ResultsAfterClose, time.Time{}, true, false},
+		{ResultsAfterClose, closed, false, true},
+		{ResultsAdminOnly, closed, true, false},
+	}
+	for _, tt := range tests {
+		p := &Poll{ResultsVisibility: tt.visibility, ClosedAt: tt.closedAt}
+		if got := p.ResultsVisible(tt.hasVoted); got != tt.want {
+			t.Errorf("%q closed=%v voted=%v: got %v, want %v", tt.visibility, p.Closed(), tt.hasVoted, got, tt.want)
+		}
+	}
+}
+
+func TestCreateResultsVisibility(t *testing.T) {
+	svc := NewService(NewMemoryRepository())
+	p, _ := svc.Create(context.Background(), "Hidden", "", "yn", []string{"Mon"}, Settings{ResultsVisibility: ResultsAfterVote})
+	if p.ResultsVisibility != ResultsAfterVote {
+		t.Errorf("got %q, want %q", p.ResultsVisibility, ResultsAfterVote)
+	}
+	p, _ = svc.Create(context.Background(), "Default", "", "yn", []string{"Mon"}, Settings{ResultsVisibility: "bogus"})
+	if p.ResultsVisibility != ResultsAlways {
+		t.Errorf("got %q, want %q", p.ResultsVisibility, ResultsAlways)
+	}
+}
+
+func TestSetClosed(t *testing.T) {
+	svc := NewService(NewMemoryRepository())
+	p, _ := svc.Create(context.Background(), "Offsite", "", "yn", []string{"Mon"}, Settings{})
+
+	if err := svc.SetClosed(context.Background(), p.ID, true); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got, _ := svc.Get(context.Background(), p.ID); !got.Closed() {
+		t.Error("expected the poll to be closed")
+	}
+	if err := svc.SetClosed(context.Background(), p.ID, false); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got, _ := svc.Get(context.Background(), p.ID); got.Closed() {
+		t.Error("expected the poll to be open again")
+	}
+	if err := svc.SetClosed(context.Background(), "doesnotexist", true); err == nil {
+		t.Error("expected error for nonexistent poll")
+	}
+}
+
+func TestAddAnonymousVote(t *testing.T) {
+	svc := NewService(NewMemoryRepository())
+	p, _ := svc.Create(context.Background(), "Sensitive", "", "yn", []string{"Mon"}, Settings{NameVisibility: NamesHidden})
+	if !p.Anonymous() {
+		t.Fatal("expected the poll to be anonymous")
+	}
+
+	for range 2 {
+		name, err := svc.AddAnonymousVote(context.Background(), p.ID, map[string]string{"Mon": "yes"})
+		if err != nil {
+			t.Fatalf("add anonymous vote: %v", err)
+		}
+		if !strings.HasPrefix(name, "anon-") {
+			t.Errorf("placeholder = %q", name)
+		}
+	}
+	got, _ := svc.Get(context.Background(), p.ID)
+	if len(got.Votes) != 2 || got.Votes[0].Name == got.Votes[1].Name {
+		t.Fatalf("expected two votes under distinct placeholders, got %+v", got.Votes)
+	}
+	if Totals(got)["Mon"].Yes != 2 {
+		t.Errorf("totals: got %+v", Totals(got))
+	}
+
+	open, _ := svc.Create(context.Background(), "Open", "", "yn", []string{"Mon"}, Settings{NameVisibility: "bogus"})
+	if open.NameVisibility != NamesPublic || open.Anonymous() {
+		t.Errorf("expected unknown name visibility to fall back to public, got %q", open.NameVisibility)
+	}
+}
+
 func TestRemoveVote(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
-	p, err := svc.Create("Offsite", "", "yn", []string{"Mon", "Tue"})
+	p, err := svc.Create(context.Background(), "Offsite", "", "yn", []string{"Mon", "Tue"}, Settings{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"Mon": "yes"})
-	_ = svc.AddVote(p.ID, "Bob", map[string]string{"Tue": "yes"})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"Mon": "yes"})
+	_ = svc.AddVote(context.Background(), p.ID, "Bob", map[string]string{"Tue": "yes"})
 
-	if err := svc.RemoveVote(p.ID, "Alice"); err != nil {
+	if err := svc.RemoveVote(context.Background(), p.ID, "Alice"); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	got, _ := svc.Get(p.ID)
+	got, _ := svc.Get(context.Background(), p.ID)
 	if len(got.Votes) != 1 {
 		t.Fatalf("expected 1 vote, got %d", len(got.Votes))
 	}
@@ -239,10 +427,10 @@ func TestRemoveVote(t *testing.T) {
 
 func TestRemoveVoteNotFound(t *testing.T) {
 	svc := NewService(NewMemoryRepository())
-	p, _ := svc.Create("Test", "", "yn", []string{"A"})
-	_ = svc.AddVote(p.ID, "Alice", map[string]string{"A": "yes"})
+	p, _ := svc.Create(context.Background(), "Test", "", "yn", []string{"A"}, Settings{})
+	_ = svc.AddVote(context.Background(), p.ID, "Alice", map[string]string{"A": "yes"})
 
-	err := svc.RemoveVote(p.ID, "Nobody")
+	err := svc.RemoveVote(context.Background(), p.ID, "Nobody")
 	if err == nil {
 		t.Fatal("expected error for nonexistent voter")
 	}
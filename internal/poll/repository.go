@@ -1,12 +1,35 @@
 package poll
 
+import (
+	"context"
+	"time"
+)
+
 // Repository defines the persistence interface for polls.
 type Repository interface {
-	Create(p *Poll) error
-	GetByPublicID(publicID string) (*Poll, error)
-	GetByAdminID(adminID string) (*Poll, error)
-	AddVote(pollID string, vote Vote) error
-	RemoveVote(pollID string, voterName string) error
-	Delete(pollID string) error
-	UpdateVote(pollID string, oldName string, vote Vote) error
+	// Create stores a new poll together with any votes it already carries.
+	Create(ctx context.Context, p *Poll) error
+	GetByPublicID(ctx context.Context, publicID string) (*Poll, error)
+	// GetByAdminHash looks a poll up by the hash of its admin ID (see
+	// HashAdminID).
+	GetByAdminHash(ctx context.Context, adminHash string) (*Poll, error)
+	// SetAdminHash replaces the admin ID hash of a poll.
+	SetAdminHash(ctx context.Context, pollID string, adminHash string) error
+	// SetClosedAt records when a poll was closed; the zero time reopens it.
+	SetClosedAt(ctx context.Context, pollID string, closedAt time.Time) error
+	AddVote(ctx context.Context, pollID string, vote Vote) error
+	RemoveVote(ctx context.Context, pollID string, voterName string) error
+	Delete(ctx context.Context, pollID string) error
+	UpdateVote(ctx context.Context, pollID string, oldName string, vote Vote) error
+	// ListExpired returns up to limit polls whose retention clock (see
+	// RetentionPolicy.Basis) is before cutoff, oldest first.
+	ListExpired(ctx context.Context, cutoff time.Time, basis string, limit int) ([]PollSummary, error)
+	// DeleteMany deletes the polls with the given public IDs and reports how
+	// many existed.
+	DeleteMany(ctx context.Context, pollIDs []string) (int, error)
+	// ListIDs returns the public IDs of all polls, oldest first.
+	ListIDs(ctx context.Context) ([]string, error)
+	// ListSummaries returns up to limit polls whose title contains query,
+	// ignoring case, newest first, after skipping offset matches.
+	ListSummaries(ctx context.Context, query string, offset, limit int) ([]PollSummary, error)
 }
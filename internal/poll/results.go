@@ -0,0 +1,56 @@
+package poll
+
+import (
+	"context"
+	"time"
+)
+
+// Results visibility policies, chosen when a poll is created. They decide
+// when participants see the names, answers, and totals of other voters; the
+// admin page always shows them.
+const (
+	ResultsAlways     = "always"      // default
+	ResultsAfterVote  = "after_vote"  // once the participant has voted, or the poll is closed
+	ResultsAfterClose = "after_close" // once the organizer has closed the poll
+	ResultsAdminOnly  = "admin_only"  // never on the participant page
+)
+
+func validResultsVisibility(v string) bool {
+	switch v {
+	case ResultsAlways, ResultsAfterVote, ResultsAfterClose, ResultsAdminOnly:
+		return true
+	}
+	return false
+}
+
+// Closed reports whether the organizer has closed the poll to new votes.
+func (p *Poll) Closed() bool {
+	return !p.ClosedAt.IsZero()
+}
+
+// ResultsVisible reports whether a participant may see the votes of others;
+// hasVoted tells whether that participant has voted on p.
+func (p *Poll) ResultsVisible(hasVoted bool) bool {
+	switch p.ResultsVisibility {
+	case ResultsAfterVote:
+		return hasVoted || p.Closed()
+	case ResultsAfterClose:
+		return p.Closed()
+	case ResultsAdminOnly:
+		return false
+	default:
+		return true
+	}
+}
+
+// SetClosed closes the poll to new votes from participants, or reopens it.
+func (s *Service) SetClosed(ctx context.Context, pollID string, closed bool) (err error) {
+	ctx, span := startSpan(ctx, "SetClosed")
+	defer func() { endSpan(span, err) }()
+
+	var closedAt time.Time
+	if closed {
+		closedAt = time.Now()
+	}
+	return s.repo.SetClosedAt(ctx, pollID, closedAt)
+}
@@ -0,0 +1,141 @@
+package poll
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Retention bases select which timestamp starts a poll's retention clock.
+const (
+	RetainSinceCreated  = "created"
+	RetainSinceLastVote = "last_vote"
+	RetainSinceClosed   = "closed"
+)
+
+// PollSummary is a lightweight view of a poll without options or responses.
+type PollSummary struct {
+	ID         string
+	Title      string
+	CreatedAt  time.Time
+	LastVoteAt time.Time // zero if nobody has voted
+	ClosedAt   time.Time // zero while the poll takes votes
+	Votes      int
+}
+
+// retentionClock returns the timestamp that basis measures a poll's age from.
+// Polls without votes fall back to their creation time. Under
+// RetainSinceClosed, open polls have no clock and the zero time is returned.
+func (s PollSummary) retentionClock(basis string) time.Time {
+	switch basis {
+	case RetainSinceLastVote:
+		if s.LastVoteAt.After(s.CreatedAt) {
+			return s.LastVoteAt
+		}
+	case RetainSinceClosed:
+		return s.ClosedAt
+	}
+	return s.CreatedAt
+}
+
+// expired reports whether the poll's retention clock under basis started
+// before cutoff. Open polls never expire under RetainSinceClosed, since
+// people may still be voting on them.
+func (s PollSummary) expired(basis string, cutoff time.Time) bool {
+	clock := s.retentionClock(basis)
+	return !clock.IsZero() && clock.Before(cutoff)
+}
+
+// RetentionPolicy describes when polls are purged automatically.
+type RetentionPolicy struct {
+	MaxAge    time.Duration // polls older than this are deleted
+	Basis     string        // RetainSinceCreated, RetainSinceLastVote or RetainSinceClosed
+	BatchSize int           // polls deleted per repository call
+	DryRun    bool          // only log what would be deleted
+}
+
+// PurgeExpired deletes every poll that policy considers expired at now, in
+// batches of policy.BatchSize. In dry-run mode it logs the first batch of
+// candidates instead and deletes nothing. It returns the number of polls
+// deleted (or that would have been).
+func (s *Service) PurgeExpired(ctx context.Context, policy RetentionPolicy, now time.Time) (n int, err error) {
+	ctx, span := startSpan(ctx, "PurgeExpired")
+	defer func() { endSpan(span, err) }()
+
+	switch policy.Basis {
+	case RetainSinceCreated, RetainSinceLastVote, RetainSinceClosed:
+	default:
+		return 0, fmt.Errorf("unknown retention basis %q", policy.Basis)
+	}
+	batch := policy.BatchSize
+	if batch <= 0 {
+		batch = 100
+	}
+	cutoff := now.Add(-policy.MaxAge)
+
+	if policy.DryRun {
+		expired, err := s.repo.ListExpired(ctx, cutoff, policy.Basis, batch)
+		if err != nil {
+			return 0, fmt.Errorf("list expired polls: %w", err)
+		}
+		for _, p := range expired {
+			slog.Info("retention dry run: would delete poll",
+				"poll", p.ID, "title", p.Title, "created_at", p.CreatedAt, "last_vote_at", p.LastVoteAt, "closed_at", p.ClosedAt, "votes", p.Votes)
+		}
+		if len(expired) == batch {
+			slog.Info("retention dry run: more polls may be eligible", "listed", batch)
+		}
+		return len(expired), nil
+	}
+
+	total := 0
+	for {
+		expired, err := s.repo.ListExpired(ctx, cutoff, policy.Basis, batch)
+		if err != nil {
+			return total, fmt.Errorf("list expired polls: %w", err)
+		}
+		if len(expired) == 0 {
+			return total, nil
+		}
+		ids := make([]string, len(expired))
+		for i, p := range expired {
+			ids[i] = p.ID
+		}
+		n, err := s.repo.DeleteMany(ctx, ids)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("delete expired polls: %w", err)
+		}
+		if len(expired) < batch || n == 0 {
+			return total, nil
+		}
+	}
+}
+
+// RunRetention applies policy immediately and then every interval until ctx
+// is cancelled. Errors are logged and retried on the next run.
+func (s *Service) RunRetention(ctx context.Context, policy RetentionPolicy, interval time.Duration) {
+	run := func() {
+		n, err := s.PurgeExpired(ctx, policy, time.Now())
+		if err != nil {
+			slog.Error("retention run failed", "err", err, "deleted", n)
+			return
+		}
+		if n > 0 && !policy.DryRun {
+			slog.Info("retention deleted expired polls", "count", n)
+		}
+	}
+
+	run()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run()
+		}
+	}
+}
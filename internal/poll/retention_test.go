@@ -0,0 +1,117 @@
+package poll
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func seedAged(t *testing.T, repo Repository, id string, created time.Time, votedAt ...time.Time) {
+	t.Helper()
+	if err := repo.Create(context.Background(), &Poll{ID: id, AdminHash: "adm_" + id, Title: id, Options: []string{"A"}, CreatedAt: created}); err != nil {
+		t.Fatalf("create %s: %v", id, err)
+	}
+	for i, at := range votedAt {
+		vote := Vote{Name: string(rune('a' + i)), Responses: map[string]string{"A": "yes"}, VotedAt: at}
+		if err := repo.AddVote(context.Background(), id, vote); err != nil {
+			t.Fatalf("vote %s: %v", id, err)
+		}
+	}
+}
+
+func TestPurgeExpiredByCreation(t *testing.T) {
+	repo := NewMemoryRepository()
+	svc := NewService(repo)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	seedAged(t, repo, "old", now.AddDate(0, 0, -40), now.AddDate(0, 0, -1))
+	seedAged(t, repo, "older", now.AddDate(0, 0, -90))
+	seedAged(t, repo, "fresh", now.AddDate(0, 0, -5))
+
+	n, err := svc.PurgeExpired(context.Background(), RetentionPolicy{MaxAge: 30 * 24 * time.Hour, Basis: RetainSinceCreated, BatchSize: 1}, now)
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d polls, want 2", n)
+	}
+	for id, wantKept := range map[string]bool{"old": false, "older": false, "fresh": true} {
+		p, _ := svc.Get(context.Background(), id)
+		if (p != nil) != wantKept {
+			t.Errorf("poll %s kept = %v, want %v", id, p != nil, wantKept)
+		}
+	}
+}
+
+func TestPurgeExpiredByLastVote(t *testing.T) {
+	repo := NewMemoryRepository()
+	svc := NewService(repo)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	seedAged(t, repo, "active", now.AddDate(0, 0, -40), now.AddDate(0, 0, -60), now.AddDate(0, 0, -2))
+	seedAged(t, repo, "stale", now.AddDate(0, 0, -50), now.AddDate(0, 0, -45))
+	seedAged(t, repo, "abandoned", now.AddDate(0, 0, -31))
+
+	n, err := svc.PurgeExpired(context.Background(), RetentionPolicy{MaxAge: 30 * 24 * time.Hour, Basis: RetainSinceLastVote}, now)
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d polls, want 2", n)
+	}
+	if p, _ := svc.Get(context.Background(), "active"); p == nil {
+		t.Error("expected poll with a recent vote to be kept")
+	}
+}
+
+func TestPurgeExpiredByClosing(t *testing.T) {
+	repo := NewMemoryRepository()
+	svc := NewService(repo)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	seedAged(t, repo, "closed_long_ago", now.AddDate(0, 0, -90))
+	seedAged(t, repo, "closed_recently", now.AddDate(0, 0, -90))
+	seedAged(t, repo, "open_old", now.AddDate(0, 0, -40))
+	seedAged(t, repo, "open_new", now.AddDate(0, 0, -5))
+	for id, closed := range map[string]time.Time{"closed_long_ago": now.AddDate(0, 0, -31), "closed_recently": now.AddDate(0, 0, -3)} {
+		if err := repo.SetClosedAt(context.Background(), id, closed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := svc.PurgeExpired(context.Background(), RetentionPolicy{MaxAge: 30 * 24 * time.Hour, Basis: RetainSinceClosed}, now)
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("deleted %d polls, want 1", n)
+	}
+	for id, wantKept := range map[string]bool{"closed_long_ago": false, "closed_recently": true, "open_old": true, "open_new": true} {
+		p, _ := svc.Get(context.Background(), id)
+		if (p != nil) != wantKept {
+			t.Errorf("poll %s kept = %v, want %v", id, p != nil, wantKept)
+		}
+	}
+}
+
+func TestPurgeExpiredDryRun(t *testing.T) {
+	repo := NewMemoryRepository()
+	svc := NewService(repo)
+	now := time.Now()
+	seedAged(t, repo, "old", now.AddDate(0, 0, -40))
+
+	n, err := svc.PurgeExpired(context.Background(), RetentionPolicy{MaxAge: 24 * time.Hour, Basis: RetainSinceCreated, DryRun: true}, now)
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("reported %d polls, want 1", n)
+	}
+	if p, _ := svc.Get(context.Background(), "old"); p == nil {
+		t.Error("dry run must not delete polls")
+	}
+}
+
+func TestPurgeExpiredUnknownBasis(t *testing.T) {
+	svc := NewService(NewMemoryRepository())
+	if _, err := svc.PurgeExpired(context.Background(), RetentionPolicy{MaxAge: time.Hour, Basis: "deadline"}, time.Now()); err == nil {
+		t.Fatal("expected error for unknown basis")
+	}
+}
@@ -0,0 +1,65 @@
+package secret
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCreatesKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "meetkat.key")
+
+	key, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(key) != KeySize {
+		t.Errorf("key length: got %d, want %d", len(key), KeySize)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("key file mode: got %o, want 600", mode)
+	}
+
+	again, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Error("expected the stored key to be loaded again")
+	}
+}
+
+func TestLoadRejectsBadKeys(t *testing.T) {
+	tests := map[string]string{
+		"not hex":   "zz" + strings.Repeat("00", KeySize),
+		"too short": strings.Repeat("ab", KeySize-1),
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "meetkat.key")
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestDerive(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	a, b := Derive(key, "admin-id"), Derive(key, "csrf")
+	if bytes.Equal(a, b) {
+		t.Error("expected different subkeys for different purposes")
+	}
+	if !bytes.Equal(a, Derive(key, "admin-id")) {
+		t.Error("expected Derive to be deterministic")
+	}
+}
@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"meetkat/internal/poll"
+)
+
+// hashAdminIDs is the hook of migration 005, which replaces polls.admin_id
+// by admin_hash. It reads the plain admin IDs before the migration drops
+// their column and stores their keyed hashes (see poll.HashAdminID) once the
+// new column exists.
+func hashAdminIDs(tx *sql.Tx, adminKey []byte, apply func() error) error {
+	type hashed struct {
+		id   int64
+		hash string
+	}
+	rows, err := tx.Query("SELECT id, admin_id FROM polls WHERE admin_id != ''")
+	if err != nil {
+		return fmt.Errorf("query admin ids: %w", err)
+	}
+	var polls []hashed
+	for rows.Next() {
+		var id int64
+		var adminID string
+		if err := rows.Scan(&id, &adminID); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan admin id: %w", err)
+		}
+		polls = append(polls, hashed{id: id, hash: poll.HashAdminID(adminKey, adminID)})
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("query admin ids: %w", err)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query admin ids: %w", err)
+	}
+	if len(polls) > 0 && len(adminKey) == 0 {
+		return errors.New("no admin key to hash the admin ids of existing polls with")
+	}
+
+	if err := apply(); err != nil {
+		return err
+	}
+	for _, p := range polls {
+		if _, err := tx.Exec("UPDATE polls SET admin_hash = ? WHERE id = ?", p.hash, p.id); err != nil {
+			return fmt.Errorf("store admin hash: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	snapshotPrefix = "meetkat-"
+	snapshotSuffix = ".db"
+	snapshotLayout = "20060102T150405Z"
+)
+
+// Backup writes a transactionally consistent copy of db to dest using
+// VACUUM INTO, which is safe while the server keeps serving requests, and then
+// verifies the integrity of the written file. dest must not exist yet.
+func Backup(db *sql.DB, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup target %s already exists", dest)
+	}
+	if _, err := db.Exec("VACUUM INTO ?", dest); err != nil {
+		return fmt.Errorf("vacuum into %s: %w", dest, err)
+	}
+	if err := os.Chmod(dest, 0o600); err != nil {
+		slog.Warn("could not set backup file permissions", "path", dest, "err", err)
+	}
+	if err := VerifyIntegrity(dest); err != nil {
+		_ = os.Remove(dest)
+		return err
+	}
+	return nil
+}
+
+// VerifyIntegrity opens the database file at path and checks that SQLite's
+// integrity check passes and that it carries a meetkat schema.
+func VerifyIntegrity(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("integrity check %s: %w", path, err)
+	}
+	defer func() { _ = rows.Close() }()
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return fmt.Errorf("scan integrity check: %w", err)
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("integrity check %s: %w", path, err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check %s failed: %s", path, strings.Join(problems, "; "))
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&n); err != nil {
+		return fmt.Errorf("%s is not a meetkat database: %w", path, err)
+	}
+	return nil
+}
+
+// Restore replaces the database at dbPath with a verified copy of the backup
+// at src. The server must not be running. The replaced database, if any, is
+// kept next to it as dbPath + ".pre-restore".
+func Restore(src, dbPath string) error {
+	if err := VerifyIntegrity(src); err != nil {
+		return err
+	}
+
+	tmp := dbPath + ".restore-tmp"
+	_ = os.Remove(tmp)
+	srcDB, err := sql.Open("sqlite", src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", src, err)
+	}
+	_, err = srcDB.Exec("VACUUM INTO ?", tmp)
+	_ = srcDB.Close()
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+".pre-restore"); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("keep current database: %w", err)
+		}
+	}
+	// Leftover WAL/SHM files belong to the old database and would be replayed
+	// on top of the restored one.
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("remove %s: %w", dbPath+suffix, err)
+		}
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return fmt.Errorf("move restored database into place: %w", err)
+	}
+	if err := os.Chmod(dbPath, 0o600); err != nil {
+		slog.Warn("could not set DB file permissions", "err", err)
+	}
+	return nil
+}
+
+// Snapshot writes a timestamped backup into dir and deletes all but the keep
+// newest snapshots. It returns the path of the new snapshot.
+func Snapshot(db *sql.DB, dir string, keep int, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("create backup directory: %w", err)
+	}
+	dest := filepath.Join(dir, snapshotPrefix+now.UTC().Format(snapshotLayout)+snapshotSuffix)
+	if err := Backup(db, dest); err != nil {
+		return "", err
+	}
+	if err := pruneSnapshots(dir, keep); err != nil {
+		return dest, err
+	}
+	return dest, nil
+}
+
+// pruneSnapshots removes the oldest snapshots in dir until at most keep
+// remain. Files not named like a snapshot are left alone.
+func pruneSnapshots(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read backup directory: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix)
+		if _, err := time.Parse(snapshotLayout, stamp); err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	// The timestamp layout sorts lexically in chronological order.
+	sort.Strings(names)
+	for len(names) > keep {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil {
+			return fmt.Errorf("remove old snapshot: %w", err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// RunSnapshots writes a snapshot every interval until ctx is cancelled.
+// Failures are logged and retried on the next tick.
+func RunSnapshots(ctx context.Context, db *sql.DB, dir string, interval time.Duration, keep int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			path, err := Snapshot(db, dir, keep, now)
+			if err != nil {
+				slog.Error("scheduled backup failed", "err", err)
+				continue
+			}
+			slog.Info("scheduled backup written", "path", path)
+		}
+	}
+}
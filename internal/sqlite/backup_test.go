@@ -0,0 +1,118 @@
+package sqlite
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"meetkat/internal/poll"
+)
+
+func openFileTestDB(t *testing.T, path string) *PollRepository {
+	t.Helper()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return NewPollRepository(db)
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "meetkat.db")
+	repo := openFileTestDB(t, dbPath)
+
+	if err := repo.Create(context.Background(), &poll.Poll{ID: "bak12345", AdminHash: "adm_bak1", Title: "Backup", Options: []string{"A"}}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	_ = repo.AddVote(context.Background(), "bak12345", poll.Vote{Name: "Alice", Responses: map[string]string{"A": "yes"}})
+
+	backupPath := filepath.Join(dir, "backup.db")
+	if err := Backup(repo.db, backupPath); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if err := Backup(repo.db, backupPath); err == nil {
+		t.Fatal("expected error when backup target exists")
+	}
+
+	// Changes after the backup must be gone after restoring it.
+	_ = repo.Delete(context.Background(), "bak12345")
+	_ = repo.db.Close()
+
+	if err := Restore(backupPath, dbPath); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := os.Stat(dbPath + ".pre-restore"); err != nil {
+		t.Errorf("expected previous database to be kept: %v", err)
+	}
+
+	restored := openFileTestDB(t, dbPath)
+	got, err := restored.GetByPublicID(context.Background(), "bak12345")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got == nil {
+		t.Fatal("expected poll to be restored")
+	}
+	if len(got.Votes) != 1 || got.Votes[0].Name != "Alice" {
+		t.Errorf("votes: got %+v, want Alice", got.Votes)
+	}
+}
+
+func TestVerifyIntegrityRejectsNonDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "garbage.db")
+	if err := os.WriteFile(path, []byte("definitely not sqlite"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyIntegrity(path); err == nil {
+		t.Fatal("expected integrity check to fail")
+	}
+	if err := VerifyIntegrity(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestSnapshotRotation(t *testing.T) {
+	dir := t.TempDir()
+	repo := openFileTestDB(t, filepath.Join(dir, "meetkat.db"))
+	backupDir := filepath.Join(dir, "backups")
+
+	// Unrelated files in the backup directory must survive pruning.
+	if err := os.MkdirAll(backupDir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDir, "notes.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var paths []string
+	for i := 0; i < 4; i++ {
+		p, err := Snapshot(repo.db, backupDir, 2, start.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+		paths = append(paths, p)
+	}
+
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d files, want 2 snapshots + notes.txt", len(entries))
+	}
+	for _, p := range paths[:2] {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be pruned", p)
+		}
+	}
+	for _, p := range paths[2:] {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("expected %s to be kept: %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package sqlite
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// ErrPendingMigrations is returned by Migrator.Check when the database schema
+// is behind this build.
+var ErrPendingMigrations = errors.New("database has pending migrations")
+
+// MigrationState describes one migration as seen by Migrator.Status.
+type MigrationState struct {
+	Version    string
+	Applied    bool
+	AppliedAt  time.Time // zero if not applied or applied before it was recorded
+	Modified   bool      // applied, but the file no longer matches the recorded checksum
+	Unverified bool      // applied before checksums were recorded, so it cannot be checked
+	Unknown    bool      // recorded in the database but not shipped with this build
+}
+
+// migration is a paired NNN_name.up.sql / NNN_name.down.sql file set. The
+// version is the file name without the suffix.
+type migration struct {
+	version  string
+	up       string
+	down     string
+	checksum string
+	// warning is the "-- WARNING:" comment of the down file, which tells
+	// what reverting the migration destroys.
+	warning string
+}
+
+// downWarningPrefix starts the comment line of a down file that warns about
+// data the revert destroys; see Migrator.DownWarnings.
+const downWarningPrefix = "-- WARNING:"
+
+// A hook wraps the SQL of an up migration with Go code for data changes that
+// SQL cannot express, such as hashing with the admin key. It must call apply,
+// which executes the migration's SQL in tx, exactly once.
+type hook func(tx *sql.Tx, adminKey []byte, apply func() error) error
+
+// upHooks holds the hooks of up migrations by version.
+var upHooks = map[string]hook{
+	"005_hash_admin_id": hashAdminIDs,
+}
+
+// Migrator inspects and changes the schema version of a database using the
+// migrations embedded in the binary.
+type Migrator struct {
+	db   *sql.DB
+	fsys fs.FS // holds the files under "migrations/"
+	// adminKey hashes the admin IDs of existing polls (see
+	// poll.HashAdminID) when migrating a database that stores them plainly.
+	adminKey []byte
+}
+
+// NewMigrator returns a Migrator for db. adminKey must match the key given to
+// poll.Service.SetAdminKey; it may be nil for databases without polls.
+func NewMigrator(db *sql.DB, adminKey []byte) *Migrator {
+	return &Migrator{db: db, fsys: migrationFS, adminKey: adminKey}
+}
+
+// Status lists every known migration in order, followed by any versions the
+// database records that this build does not know about. Like Check, it only
+// reads the database.
+func (m *Migrator) Status() ([]MigrationState, error) {
+	migs, applied, err := m.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migs))
+	known := make(map[string]bool, len(migs))
+	for _, mig := range migs {
+		known[mig.version] = true
+		st := MigrationState{Version: mig.version}
+		if rec, ok := applied[mig.version]; ok {
+			st.Applied = true
+			st.AppliedAt = rec.appliedAt
+			st.Unverified = rec.checksum == ""
+			st.Modified = !st.Unverified && rec.checksum != mig.checksum
+		}
+		states = append(states, st)
+	}
+	for _, version := range sortedVersions(applied) {
+		if !known[version] {
+			states = append(states, MigrationState{Version: version, Applied: true, AppliedAt: applied[version].appliedAt, Unknown: true})
+		}
+	}
+	return states, nil
+}
+
+// Check verifies the checksums of applied migrations and returns an error
+// wrapping ErrPendingMigrations if any migration still has to be applied. It
+// only reads the database.
+func (m *Migrator) Check() error {
+	_, pending, err := m.pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		versions := make([]string, len(pending))
+		for i, mig := range pending {
+			versions[i] = mig.version
+		}
+		return fmt.Errorf("%w: %s", ErrPendingMigrations, strings.Join(versions, ", "))
+	}
+	return nil
+}
+
+// Up applies all pending migrations, each in its own transaction, and returns
+// the versions applied. With dryRun, the migrations are executed in a single
+// transaction that is rolled back, so their SQL is checked without changing
+// the database.
+//
+// Up also upgrades a schema_migrations table written by an older build and
+// records the checksums of the migrations it lists from the files of this
+// build. Nothing else writes to schema_migrations outside a migration.
+func (m *Migrator) Up(dryRun bool) ([]string, error) {
+	migs, pending, err := m.pending()
+	if err != nil {
+		return nil, err
+	}
+	return m.run(migs, pending, false, dryRun)
+}
+
+// Down reverts the steps most recently applied migrations using their down
+// files and returns the versions reverted. dryRun behaves as for Up.
+func (m *Migrator) Down(steps int, dryRun bool) ([]string, error) {
+	migs, applied, err := m.prepare()
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(migs, applied); err != nil {
+		return nil, err
+	}
+
+	var revert []migration
+	for i := len(migs) - 1; i >= 0 && len(revert) < steps; i-- {
+		if _, ok := applied[migs[i].version]; ok {
+			revert = append(revert, migs[i])
+		}
+	}
+	return m.run(migs, revert, true, dryRun)
+}
+
+// pending returns all migrations and those not yet applied, after verifying
+// the ones that are.
+func (m *Migrator) pending() (migs, pending []migration, err error) {
+	migs, applied, err := m.prepare()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verify(migs, applied); err != nil {
+		return nil, nil, err
+	}
+
+	for _, mig := range migs {
+		if _, ok := applied[mig.version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return migs, pending, nil
+}
+
+// DownWarnings returns the warnings of the down files that Down(steps) would
+// run, prefixed with their versions. Reverting these migrations destroys data
+// that cannot be restored by migrating up again.
+func (m *Migrator) DownWarnings(steps int) ([]string, error) {
+	migs, applied, err := m.prepare()
+	if err != nil {
+		return nil, err
+	}
+	var warnings []string
+	for i, n := len(migs)-1, 0; i >= 0 && n < steps; i-- {
+		if _, ok := applied[migs[i].version]; !ok {
+			continue
+		}
+		n++
+		if migs[i].warning != "" {
+			warnings = append(warnings, migs[i].version+": "+migs[i].warning)
+		}
+	}
+	return warnings, nil
+}
+
+// run applies (or with down, reverts) migs in order and returns the versions
+// that were processed. known holds every migration of this build, for
+// upgrading schema_migrations first.
+func (m *Migrator) run(known, migs []migration, down, dryRun bool) ([]string, error) {
+	var done []string
+	if dryRun {
+		tx, err := m.db.Begin()
+		if err != nil {
+			return nil, fmt.Errorf("begin dry run: %w", err)
+		}
+		defer func() { _ = tx.Rollback() }()
+		if err := ensureTable(tx, known); err != nil {
+			return nil, err
+		}
+		for _, mig := range migs {
+			if err := m.step(tx, mig, down); err != nil {
+				return done, err
+			}
+			done = append(done, mig.version)
+		}
+		return done, nil
+	}
+
+	if err := ensureTable(m.db, known); err != nil {
+		return nil, err
+	}
+	for _, mig := range migs {
+		tx, err := m.db.Begin()
+		if err != nil {
+			return done, fmt.Errorf("begin tx for %s: %w", mig.version, err)
+		}
+		if err := m.step(tx, mig, down); err != nil {
+			_ = tx.Rollback()
+			return done, err
+		}
+		if err := tx.Commit(); err != nil {
+			return done, fmt.Errorf("commit migration %s: %w", mig.version, err)
+		}
+		done = append(done, mig.version)
+	}
+	return done, nil
+}
+
+// step executes one migration inside tx, with its hook if it has one, and
+// records the result in schema_migrations.
+func (m *Migrator) step(tx *sql.Tx, mig migration, down bool) error {
+	if down {
+		if _, err := tx.Exec(mig.down); err != nil {
+			return fmt.Errorf("exec down migration %s: %w", mig.version, err)
+		}
+		if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", mig.version); err != nil {
+			return fmt.Errorf("unrecord migration %s: %w", mig.version, err)
+		}
+		return nil
+	}
+
+	apply := func() error {
+		if _, err := tx.Exec(mig.up); err != nil {
+			return fmt.Errorf("exec migration %s: %w", mig.version, err)
+		}
+		return nil
+	}
+	if h, ok := upHooks[mig.version]; ok {
+		if err := h(tx, m.adminKey, apply); err != nil {
+			return fmt.Errorf("migration %s: %w", mig.version, err)
+		}
+	} else if err := apply(); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO schema_migrations (version, checksum, applied_at) VALUES (?, ?, datetime('now'))",
+		mig.version, mig.checksum,
+	); err != nil {
+		return fmt.Errorf("record migration %s: %w", mig.version, err)
+	}
+	return nil
+}
+
+// appliedMigration is a row of schema_migrations. The checksum is empty for
+// migrations recorded before checksums were tracked.
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// execQuerier is satisfied by *sql.DB and *sql.Tx.
+type execQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// prepare loads the migration files and the applied versions. It only reads
+// the database: a missing schema_migrations table means nothing is applied,
+// and a table from an older build without checksums yields empty checksums.
+func (m *Migrator) prepare() ([]migration, map[string]appliedMigration, error) {
+	migs, err := m.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	exists, hasChecksum, err := inspectTable(m.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	applied := make(map[string]appliedMigration)
+	if !exists {
+		return migs, applied, nil
+	}
+
+	query := "SELECT version, checksum, COALESCE(applied_at, '') FROM schema_migrations"
+	if !hasChecksum {
+		query = "SELECT version, '', '' FROM schema_migrations"
+	}
+	rows, err := m.db.Query(query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var version, checksum, appliedAt string
+		if err := rows.Scan(&version, &checksum, &appliedAt); err != nil {
+			return nil, nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		rec := appliedMigration{checksum: checksum}
+		if appliedAt != "" {
+			rec.appliedAt, _ = time.Parse(sqliteTimeLayout, appliedAt)
+		}
+		applied[version] = rec
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	return migs, applied, nil
+}
+
+// inspectTable reports whether schema_migrations exists and whether it has
+// the checksum column added by newer builds.
+func inspectTable(q execQuerier) (exists, hasChecksum bool, err error) {
+	var tables, checksums int
+	if err := q.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'",
+	).Scan(&tables); err != nil {
+		return false, false, fmt.Errorf("inspect schema_migrations: %w", err)
+	}
+	if err := q.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info('schema_migrations') WHERE name = 'checksum'",
+	).Scan(&checksums); err != nil {
+		return false, false, fmt.Errorf("inspect schema_migrations: %w", err)
+	}
+	return tables > 0, checksums > 0, nil
+}
+
+// ensureTable creates schema_migrations, adds the checksum and applied_at
+// columns to tables created by older builds, and fills in checksums for rows
+// recorded before they were tracked. It runs only when migrations are
+// applied or reverted.
+func ensureTable(q execQuerier, migs []migration) error {
+	_, err := q.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		checksum   TEXT NOT NULL DEFAULT '',
+		applied_at TEXT
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	_, hasChecksum, err := inspectTable(q)
+	if err != nil {
+		return err
+	}
+	if !hasChecksum {
+		for _, stmt := range []string{
+			"ALTER TABLE schema_migrations ADD COLUMN checksum TEXT NOT NULL DEFAULT ''",
+			"ALTER TABLE schema_migrations ADD COLUMN applied_at TEXT",
+		} {
+			if _, err := q.Exec(stmt); err != nil {
+				return fmt.Errorf("upgrade schema_migrations: %w", err)
+			}
+		}
+	}
+
+	for _, mig := range migs {
+		if _, err := q.Exec(
+			"UPDATE schema_migrations SET checksum = ? WHERE version = ? AND checksum = ''",
+			mig.checksum, mig.version,
+		); err != nil {
+			return fmt.Errorf("backfill checksum for %s: %w", mig.version, err)
+		}
+	}
+	return nil
+}
+
+// load reads the migration files, sorted by version. Every up file must have
+// a matching down file and vice versa.
+func (m *Migrator) load() ([]migration, error) {
+	entries, err := fs.ReadDir(m.fsys, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations dir: %w", err)
+	}
+
+	ups := make(map[string]string)
+	downs := make(map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		content, err := fs.ReadFile(m.fsys, "migrations/"+name)
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", name, err)
+		}
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			ups[strings.TrimSuffix(name, ".up.sql")] = string(content)
+		case strings.HasSuffix(name, ".down.sql"):
+			downs[strings.TrimSuffix(name, ".down.sql")] = string(content)
+		default:
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", name)
+		}
+	}
+
+	migs := make([]migration, 0, len(ups))
+	for version, up := range ups {
+		down, ok := downs[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %s has no down file", version)
+		}
+		sum := sha256.Sum256([]byte(up))
+		migs = append(migs, migration{
+			version:  version,
+			up:       up,
+			down:     down,
+			checksum: hex.EncodeToString(sum[:]),
+			warning:  downWarning(down),
+		})
+	}
+	for version := range downs {
+		if _, ok := ups[version]; !ok {
+			return nil, fmt.Errorf("migration %s has no up file", version)
+		}
+	}
+
+	// Sort by version to ensure correct order.
+	sort.Slice(migs, func(i, j int) bool {
+		return migs[i].version < migs[j].version
+	})
+	return migs, nil
+}
+
+// downWarning returns the text of the first "-- WARNING:" line of a down
+// file, or "" if there is none.
+func downWarning(down string) string {
+	for line := range strings.Lines(down) {
+		if text, ok := strings.CutPrefix(strings.TrimSpace(line), downWarningPrefix); ok {
+			return strings.TrimSpace(text)
+		}
+	}
+	return ""
+}
+
+// verify checks that every applied migration is known to this build and
+// unchanged since it was applied. Migrations recorded without a checksum
+// cannot be checked and pass.
+func verify(migs []migration, applied map[string]appliedMigration) error {
+	byVersion := make(map[string]migration, len(migs))
+	for _, mig := range migs {
+		byVersion[mig.version] = mig
+	}
+	for _, version := range sortedVersions(applied) {
+		mig, ok := byVersion[version]
+		if !ok {
+			return fmt.Errorf("migration %s is recorded in the database but unknown to this build", version)
+		}
+		if rec := applied[version]; rec.checksum != "" && rec.checksum != mig.checksum {
+			return fmt.Errorf("migration %s was modified after it was applied (checksum %.12s, recorded %.12s)",
+				version, mig.checksum, rec.checksum)
+		}
+	}
+	return nil
+}
+
+func sortedVersions(applied map[string]appliedMigration) []string {
+	versions := make([]string, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
@@ -0,0 +1,337 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"meetkat/internal/poll"
+)
+
+func connectTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Connect(filepath.Join(t.TempDir(), "meetkat.db"))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n); err != nil {
+		t.Fatalf("inspect schema: %v", err)
+	}
+	return n > 0
+}
+
+func testMigrations(up2 string) fstest.MapFS {
+	return fstest.MapFS{
+		"migrations/001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"migrations/001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"migrations/002_b.up.sql":   {Data: []byte(up2)},
+		"migrations/002_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	}
+}
+
+func TestEmbeddedMigrationsRoundTrip(t *testing.T) {
+	db := connectTestDB(t)
+	m := NewMigrator(db, nil)
+
+	applied, err := m.Up(false)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(applied) == 0 {
+		t.Fatal("expected embedded migrations to be applied")
+	}
+	if err := m.Check(); err != nil {
+		t.Fatalf("check after up: %v", err)
+	}
+
+	reverted, err := m.Down(len(applied), false)
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if len(reverted) != len(applied) || reverted[0] != applied[len(applied)-1] {
+		t.Errorf("down reverted %v, want %v in reverse", reverted, applied)
+	}
+	if tableExists(t, db, "polls") {
+		t.Error("expected polls table to be dropped")
+	}
+	if err := m.Check(); !errors.Is(err, ErrPendingMigrations) {
+		t.Errorf("check after down: got %v, want ErrPendingMigrations", err)
+	}
+
+	if _, err := m.Up(false); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+	if !tableExists(t, db, "polls") {
+		t.Error("expected polls table after re-applying")
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	db := connectTestDB(t)
+	m := &Migrator{db: db, fsys: testMigrations("CREATE TABLE b (id INTEGER);")}
+
+	versions, err := m.Up(true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("dry run: got %v, want both migrations", versions)
+	}
+	if tableExists(t, db, "a") {
+		t.Error("dry run must not create tables")
+	}
+	states, _ := m.Status()
+	for _, st := range states {
+		if st.Applied {
+			t.Errorf("dry run recorded %s as applied", st.Version)
+		}
+	}
+
+	bad := &Migrator{db: db, fsys: testMigrations("NOT SQL")}
+	if _, err := bad.Up(true); err == nil {
+		t.Error("expected dry run to report broken migration")
+	}
+}
+
+func TestMigrateDownSteps(t *testing.T) {
+	db := connectTestDB(t)
+	m := &Migrator{db: db, fsys: testMigrations("CREATE TABLE b (id INTEGER);")}
+	if _, err := m.Up(false); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	reverted, err := m.Down(1, false)
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0] != "002_b" {
+		t.Errorf("down: got %v, want [002_b]", reverted)
+	}
+	if !tableExists(t, db, "a") || tableExists(t, db, "b") {
+		t.Error("expected only the latest migration to be reverted")
+	}
+
+	states, err := m.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(states) != 2 || !states[0].Applied || states[0].AppliedAt.IsZero() || states[1].Applied {
+		t.Errorf("unexpected status %+v", states)
+	}
+}
+
+func TestMigrateDetectsModifiedMigration(t *testing.T) {
+	db := connectTestDB(t)
+	m := &Migrator{db: db, fsys: testMigrations("CREATE TABLE b (id INTEGER);")}
+	if _, err := m.Up(false); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	edited := &Migrator{db: db, fsys: testMigrations("CREATE TABLE b (id INTEGER, extra TEXT);")}
+	if _, err := edited.Up(false); err == nil {
+		t.Error("expected up to refuse an edited migration")
+	}
+	if err := edited.Check(); err == nil || errors.Is(err, ErrPendingMigrations) {
+		t.Errorf("check: got %v, want checksum error", err)
+	}
+	states, err := edited.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if states[0].Modified || !states[1].Modified {
+		t.Errorf("expected only 002_b to be reported modified, got %+v", states)
+	}
+}
+
+func TestMigrateReportsUnknownVersions(t *testing.T) {
+	db := connectTestDB(t)
+	m := &Migrator{db: db, fsys: testMigrations("CREATE TABLE b (id INTEGER);")}
+	if _, err := m.Up(false); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	older := &Migrator{db: db, fsys: fstest.MapFS{
+		"migrations/001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"migrations/001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	}}
+	if err := older.Check(); err == nil {
+		t.Error("expected check to fail for a database newer than the build")
+	}
+	states, err := older.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(states) != 2 || !states[1].Unknown {
+		t.Errorf("expected 002_b to be reported unknown, got %+v", states)
+	}
+}
+
+func TestMigrateRequiresPairedFiles(t *testing.T) {
+	db := connectTestDB(t)
+	fsys := testMigrations("CREATE TABLE b (id INTEGER);")
+	delete(fsys, "migrations/002_b.down.sql")
+	m := &Migrator{db: db, fsys: fsys}
+	if _, err := m.Up(false); err == nil {
+		t.Error("expected error for migration without down file")
+	}
+}
+
+func TestMigrateUpgradesLegacyTable(t *testing.T) {
+	db := connectTestDB(t)
+	if _, err := db.Exec(`CREATE TABLE schema_migrations (version TEXT PRIMARY KEY);
+		CREATE TABLE a (id INTEGER);
+		INSERT INTO schema_migrations (version) VALUES ('001_a');`); err != nil {
+		t.Fatalf("seed legacy schema: %v", err)
+	}
+
+	m := &Migrator{db: db, fsys: testMigrations("CREATE TABLE b (id INTEGER);")}
+
+	// Inspecting the database must not upgrade the table.
+	if err := m.Check(); !errors.Is(err, ErrPendingMigrations) {
+		t.Errorf("check: got %v, want ErrPendingMigrations", err)
+	}
+	states, err := m.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !states[0].Applied || !states[0].Unverified || states[0].Modified {
+		t.Errorf("expected 001_a to be applied but unverified, got %+v", states[0])
+	}
+	if _, err := m.Up(true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	var columns int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('schema_migrations')").Scan(&columns); err != nil {
+		t.Fatal(err)
+	}
+	if columns != 1 {
+		t.Fatalf("read-only commands changed schema_migrations to %d columns", columns)
+	}
+
+	applied, err := m.Up(false)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(applied) != 1 || applied[0] != "002_b" {
+		t.Errorf("up: got %v, want [002_b]", applied)
+	}
+	if err := m.Check(); err != nil {
+		t.Errorf("check: %v", err)
+	}
+	if states, _ := m.Status(); states[0].Unverified {
+		t.Error("expected up to record the checksum of 001_a")
+	}
+}
+
+func TestMigrateInspectionIsReadOnly(t *testing.T) {
+	db := connectTestDB(t)
+	m := &Migrator{db: db, fsys: testMigrations("CREATE TABLE b (id INTEGER);")}
+	if err := m.Check(); !errors.Is(err, ErrPendingMigrations) {
+		t.Errorf("check: got %v, want ErrPendingMigrations", err)
+	}
+	if _, err := m.Status(); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if _, err := m.Up(true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if tableExists(t, db, "schema_migrations") {
+		t.Error("check, status and dry run created schema_migrations")
+	}
+}
+
+func TestMigrateHashesAdminIDs(t *testing.T) {
+	db := connectTestDB(t)
+	m := NewMigrator(db, nil)
+	if _, err := m.Up(false); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	// Roll back to just before 005_hash_admin_id.
+	states, err := m.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	steps := 0
+	for _, st := range states {
+		if st.Version >= "005" {
+			steps++
+		}
+	}
+	if _, err := m.Down(steps, false); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO polls (public_id, admin_id, title, created_at) VALUES ('pub1', 'secretadmin', 'T', '2026-01-01T00:00:00Z')"); err != nil {
+		t.Fatalf("seed poll: %v", err)
+	}
+
+	if _, err := m.Up(false); err == nil {
+		t.Fatal("expected migration to fail without an admin key")
+	}
+	key := []byte("test key")
+	if _, err := NewMigrator(db, key).Up(false); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	p, err := NewPollRepository(db).GetByAdminHash(context.Background(), poll.HashAdminID(key, "secretadmin"))
+	if err != nil {
+		t.Fatalf("get by admin hash: %v", err)
+	}
+	if p == nil || p.ID != "pub1" {
+		t.Errorf("expected poll pub1 to be found by its admin hash, got %+v", p)
+	}
+}
+
+func TestMigrateDownWarnings(t *testing.T) {
+	db := connectTestDB(t)
+	fsys := testMigrations("CREATE TABLE b (id INTEGER);")
+	fsys["migrations/001_a.down.sql"] = &fstest.MapFile{Data: []byte("-- WARNING: drops every a\nDROP TABLE a;")}
+	m := &Migrator{db: db, fsys: fsys}
+	if _, err := m.Up(false); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	if warnings, err := m.DownWarnings(1); err != nil || len(warnings) != 0 {
+		t.Errorf("one step: got %v, %v, want no warnings", warnings, err)
+	}
+	warnings, err := m.DownWarnings(2)
+	if err != nil {
+		t.Fatalf("down warnings: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0] != "001_a: drops every a" {
+		t.Errorf("two steps: got %v, want the warning of 001_a", warnings)
+	}
+
+	embedded, err := NewMigrator(connectTestDB(t), nil).load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for _, mig := range embedded {
+		if mig.version == "005_hash_admin_id" && mig.warning == "" {
+			t.Error("expected 005_hash_admin_id to warn that admin links are lost")
+		}
+	}
+}
+
+func TestDestructiveDownMigrationsWarn(t *testing.T) {
+	migs, err := NewMigrator(connectTestDB(t), nil).load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for _, mig := range migs {
+		down := strings.ToUpper(mig.down)
+		if (strings.Contains(down, "DROP TABLE") || strings.Contains(down, "DROP COLUMN")) && mig.warning == "" {
+			t.Errorf("%s.down.sql drops data but has no %q line", mig.version, downWarningPrefix)
+		}
+	}
+}
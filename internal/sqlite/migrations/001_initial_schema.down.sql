@@ -0,0 +1,5 @@
+-- WARNING: drops every table, so all polls, options and votes are deleted.
+DROP TABLE vote_responses;
+DROP TABLE votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
@@ -0,0 +1,3 @@
+-- WARNING: deletes every admin ID, so no poll can be edited or deleted through its admin link any more.
+DROP INDEX idx_polls_admin_id;
+ALTER TABLE polls DROP COLUMN admin_id;
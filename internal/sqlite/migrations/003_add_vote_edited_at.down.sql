@@ -0,0 +1,2 @@
+-- WARNING: deletes when each vote was last edited.
+ALTER TABLE votes DROP COLUMN edited_at;
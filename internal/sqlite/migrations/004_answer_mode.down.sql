@@ -0,0 +1,2 @@
+-- WARNING: deletes the answer mode of every poll, so whether a poll offers "maybe" is lost.
+ALTER TABLE polls DROP COLUMN answer_mode;
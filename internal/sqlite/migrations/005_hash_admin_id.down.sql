@@ -0,0 +1,5 @@
+-- WARNING: admin IDs cannot be recovered from their hashes, so every poll loses its admin link; after migrating up again, give each poll a new one with "meetkat admin-link".
+DROP INDEX idx_polls_admin_hash;
+ALTER TABLE polls DROP COLUMN admin_hash;
+ALTER TABLE polls ADD COLUMN admin_id TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX idx_polls_admin_id ON polls(admin_id) WHERE admin_id != '';
@@ -0,0 +1,6 @@
+-- The admin IDs of existing polls are hashed in Go with the admin key, see
+-- hashAdminIDs.
+ALTER TABLE polls ADD COLUMN admin_hash TEXT NOT NULL DEFAULT '';
+DROP INDEX idx_polls_admin_id;
+ALTER TABLE polls DROP COLUMN admin_id;
+CREATE UNIQUE INDEX idx_polls_admin_hash ON polls(admin_hash) WHERE admin_hash != '';
@@ -0,0 +1,2 @@
+-- WARNING: deletes every poll password, so password-protected polls become readable and open for voting without a password.
+ALTER TABLE polls DROP COLUMN password_hash;
@@ -0,0 +1 @@
+ALTER TABLE polls ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- WARNING: deletes the results visibility and closing time of every poll, so closed polls reopen for voting and hidden results become visible to everyone.
+ALTER TABLE polls DROP COLUMN closed_at;
+ALTER TABLE polls DROP COLUMN results_visibility;
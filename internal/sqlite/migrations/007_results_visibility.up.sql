@@ -0,0 +1,2 @@
+ALTER TABLE polls ADD COLUMN results_visibility TEXT NOT NULL DEFAULT 'always';
+ALTER TABLE polls ADD COLUMN closed_at TEXT;
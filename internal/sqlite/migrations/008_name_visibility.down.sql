@@ -0,0 +1,2 @@
+-- WARNING: deletes the name visibility of every poll, so anonymous polls show every voter's name to all participants.
+ALTER TABLE polls DROP COLUMN name_visibility;
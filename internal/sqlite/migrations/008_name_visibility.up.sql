@@ -0,0 +1 @@
+ALTER TABLE polls ADD COLUMN name_visibility TEXT NOT NULL DEFAULT 'public';
@@ -1,13 +1,18 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"meetkat/internal/poll"
 )
 
+// sqliteTimeLayout matches the format produced by SQLite's datetime().
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
 // PollRepository implements poll.Repository backed by SQLite.
 type PollRepository struct {
 	db *sql.DB
@@ -19,8 +24,8 @@ func NewPollRepository(db *sql.DB) *PollRepository {
 
 // withTx begins a transaction, passes it to fn, and commits on success.
 // The deferred rollback is a no-op after a successful commit.
-func (r *PollRepository) withTx(fn func(*sql.Tx) error) error {
-	tx, err := r.db.Begin()
+func (r *PollRepository) withTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
@@ -33,8 +38,8 @@ func (r *PollRepository) withTx(fn func(*sql.Tx) error) error {
 
 // loadOptionIDsByLabel queries all options for a poll (by internal row ID) and
 // returns a map from label to option row ID, for use within a transaction.
-func loadOptionIDsByLabel(tx *sql.Tx, pollRowID int64) (map[string]int64, error) {
-	rows, err := tx.Query("SELECT id, label FROM poll_options WHERE poll_id = ?", pollRowID)
+func loadOptionIDsByLabel(ctx context.Context, tx *sql.Tx, pollRowID int64) (map[string]int64, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, label FROM poll_options WHERE poll_id = ?", pollRowID)
 	if err != nil {
 		return nil, fmt.Errorf("query options: %w", err)
 	}
@@ -55,15 +60,23 @@ func loadOptionIDsByLabel(tx *sql.Tx, pollRowID int64) (map[string]int64, error)
 	return m, nil
 }
 
-func (r *PollRepository) Create(p *poll.Poll) error {
-	return r.withTx(func(tx *sql.Tx) error {
+func (r *PollRepository) Create(ctx context.Context, p *poll.Poll) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
 		answerMode := p.AnswerMode
 		if answerMode == "" {
 			answerMode = poll.AnswerModeYN
 		}
-		res, err := tx.Exec(
-			"INSERT INTO polls (public_id, admin_id, title, description, created_at, answer_mode) VALUES (?, ?, ?, ?, ?, ?)",
-			p.ID, p.AdminID, p.Title, p.Description, p.CreatedAt.UTC().Format(time.RFC3339), answerMode,
+		resultsVisibility := p.ResultsVisibility
+		if resultsVisibility == "" {
+			resultsVisibility = poll.ResultsAlways
+		}
+		nameVisibility := p.NameVisibility
+		if nameVisibility == "" {
+			nameVisibility = poll.NamesPublic
+		}
+		res, err := tx.ExecContext(ctx,
+			"INSERT INTO polls (public_id, admin_hash, title, description, created_at, answer_mode, password_hash, results_visibility, name_visibility, closed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			p.ID, p.AdminHash, p.Title, p.Description, p.CreatedAt.UTC().Format(time.RFC3339), answerMode, p.PasswordHash, resultsVisibility, nameVisibility, nullTime(p.ClosedAt),
 		)
 		if err != nil {
 			return fmt.Errorf("insert poll: %w", err)
@@ -74,39 +87,139 @@ func (r *PollRepository) Create(p *poll.Poll) error {
 			return fmt.Errorf("last insert id: %w", err)
 		}
 
+		optionIDByLabel := make(map[string]int64, len(p.Options))
 		for i, label := range p.Options {
-			_, err := tx.Exec(
+			res, err := tx.ExecContext(ctx,
 				"INSERT INTO poll_options (poll_id, label, position) VALUES (?, ?, ?)",
 				pollRowID, label, i,
 			)
 			if err != nil {
 				return fmt.Errorf("insert option %q: %w", label, err)
 			}
+			if optionIDByLabel[label], err = res.LastInsertId(); err != nil {
+				return fmt.Errorf("last insert id: %w", err)
+			}
+		}
+
+		// Polls restored from an archive arrive with their votes.
+		for _, v := range p.Votes {
+			if err := insertVote(ctx, tx, pollRowID, optionIDByLabel, v); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
 }
 
-func (r *PollRepository) GetByPublicID(publicID string) (*poll.Poll, error) {
-	return r.getPollByQuery(
-		"SELECT id, public_id, admin_id, title, description, created_at, answer_mode FROM polls WHERE public_id = ?",
+func (r *PollRepository) ListIDs(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT public_id FROM polls ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("query poll ids: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan poll id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate poll ids: %w", err)
+	}
+	return ids, nil
+}
+
+func (r *PollRepository) ListSummaries(ctx context.Context, query string, offset, limit int) ([]poll.PollSummary, error) {
+	// LIKE ignores case for ASCII letters only, which is good enough for
+	// finding a poll by its title.
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	rows, err := r.db.QueryContext(ctx, `SELECT p.public_id, p.title, p.created_at, p.closed_at, COUNT(v.id), MAX(COALESCE(v.edited_at, v.voted_at))
+		FROM polls p LEFT JOIN votes v ON v.poll_id = p.id
+		WHERE p.title LIKE ? ESCAPE '\'
+		GROUP BY p.id
+		ORDER BY p.id DESC
+		LIMIT ? OFFSET ?`, pattern, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("query poll summaries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var polls []poll.PollSummary
+	for rows.Next() {
+		s, err := scanSummary(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan poll summary: %w", err)
+		}
+		polls = append(polls, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate poll summaries: %w", err)
+	}
+	return polls, nil
+}
+
+func (r *PollRepository) GetByPublicID(ctx context.Context, publicID string) (*poll.Poll, error) {
+	return r.getPollByQuery(ctx,
+		"SELECT id, public_id, admin_hash, title, description, created_at, answer_mode, password_hash, results_visibility, name_visibility, closed_at FROM polls WHERE public_id = ?",
 		publicID,
 	)
 }
 
-func (r *PollRepository) GetByAdminID(adminID string) (*poll.Poll, error) {
-	return r.getPollByQuery(
-		"SELECT id, public_id, admin_id, title, description, created_at, answer_mode FROM polls WHERE admin_id = ?",
-		adminID,
+func (r *PollRepository) GetByAdminHash(ctx context.Context, adminHash string) (*poll.Poll, error) {
+	return r.getPollByQuery(ctx,
+		"SELECT id, public_id, admin_hash, title, description, created_at, answer_mode, password_hash, results_visibility, name_visibility, closed_at FROM polls WHERE admin_hash = ?",
+		adminHash,
 	)
 }
 
-func (r *PollRepository) getPollByQuery(query, value string) (*poll.Poll, error) {
+func (r *PollRepository) SetAdminHash(ctx context.Context, pollID string, adminHash string) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE polls SET admin_hash = ? WHERE public_id = ?", adminHash, pollID)
+	if err != nil {
+		return fmt.Errorf("update admin hash: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("poll not found")
+	}
+	return nil
+}
+
+func (r *PollRepository) SetClosedAt(ctx context.Context, pollID string, closedAt time.Time) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE polls SET closed_at = ? WHERE public_id = ?", nullTime(closedAt), pollID)
+	if err != nil {
+		return fmt.Errorf("update closed_at: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("poll not found")
+	}
+	return nil
+}
+
+// nullTime stores t as RFC 3339, or NULL if it is zero.
+func nullTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339), Valid: true}
+}
+
+func (r *PollRepository) getPollByQuery(ctx context.Context, query, value string) (*poll.Poll, error) {
 	var rowID int64
 	var p poll.Poll
 	var createdAt string
+	var closedAt sql.NullString
 
-	err := r.db.QueryRow(query, value).Scan(&rowID, &p.ID, &p.AdminID, &p.Title, &p.Description, &createdAt, &p.AnswerMode)
+	err := r.db.QueryRowContext(ctx, query, value).Scan(&rowID, &p.ID, &p.AdminHash, &p.Title, &p.Description, &createdAt, &p.AnswerMode, &p.PasswordHash, &p.ResultsVisibility, &p.NameVisibility, &closedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -115,9 +228,12 @@ func (r *PollRepository) getPollByQuery(query, value string) (*poll.Poll, error)
 	}
 
 	p.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	if closedAt.Valid {
+		p.ClosedAt, _ = time.Parse(time.RFC3339, closedAt.String)
+	}
 
 	// Load options ordered by position.
-	optRows, err := r.db.Query(
+	optRows, err := r.db.QueryContext(ctx,
 		"SELECT id, label FROM poll_options WHERE poll_id = ? ORDER BY position",
 		rowID,
 	)
@@ -150,8 +266,8 @@ func (r *PollRepository) getPollByQuery(query, value string) (*poll.Poll, error)
 	}
 
 	// Load votes.
-	voteRows, err := r.db.Query(
-		"SELECT id, name FROM votes WHERE poll_id = ? ORDER BY id",
+	voteRows, err := r.db.QueryContext(ctx,
+		"SELECT id, name, voted_at, edited_at FROM votes WHERE poll_id = ? ORDER BY id",
 		rowID,
 	)
 	if err != nil {
@@ -160,15 +276,23 @@ func (r *PollRepository) getPollByQuery(query, value string) (*poll.Poll, error)
 	defer func() { _ = voteRows.Close() }()
 
 	type voteRef struct {
-		id   int64
-		name string
+		id       int64
+		name     string
+		votedAt  time.Time
+		editedAt time.Time
 	}
 	var voteRefs []voteRef
 	for voteRows.Next() {
 		var v voteRef
-		if err := voteRows.Scan(&v.id, &v.name); err != nil {
+		var votedAt string
+		var editedAt sql.NullString
+		if err := voteRows.Scan(&v.id, &v.name, &votedAt, &editedAt); err != nil {
 			return nil, fmt.Errorf("scan vote: %w", err)
 		}
+		v.votedAt, _ = time.Parse(sqliteTimeLayout, votedAt)
+		if editedAt.Valid {
+			v.editedAt, _ = time.Parse(sqliteTimeLayout, editedAt.String)
+		}
 		voteRefs = append(voteRefs, v)
 	}
 	if err := voteRows.Err(); err != nil {
@@ -177,7 +301,7 @@ func (r *PollRepository) getPollByQuery(query, value string) (*poll.Poll, error)
 
 	// Load responses for each vote.
 	for _, vr := range voteRefs {
-		respRows, err := r.db.Query(
+		respRows, err := r.db.QueryContext(ctx,
 			"SELECT option_id, available FROM vote_responses WHERE vote_id = ?",
 			vr.id,
 		)
@@ -202,14 +326,14 @@ func (r *PollRepository) getPollByQuery(query, value string) (*poll.Poll, error)
 			return nil, fmt.Errorf("iterate responses: %w", err)
 		}
 
-		p.Votes = append(p.Votes, poll.Vote{Name: vr.name, Responses: responses})
+		p.Votes = append(p.Votes, poll.Vote{Name: vr.name, Responses: responses, VotedAt: vr.votedAt, EditedAt: vr.editedAt})
 	}
 
 	return &p, nil
 }
 
-func (r *PollRepository) RemoveVote(pollID string, voterName string) error {
-	res, err := r.db.Exec(
+func (r *PollRepository) RemoveVote(ctx context.Context, pollID string, voterName string) error {
+	res, err := r.db.ExecContext(ctx,
 		"DELETE FROM votes WHERE poll_id = (SELECT id FROM polls WHERE public_id = ?) AND name = ?",
 		pollID, voterName,
 	)
@@ -226,11 +350,11 @@ func (r *PollRepository) RemoveVote(pollID string, voterName string) error {
 	return nil
 }
 
-func (r *PollRepository) AddVote(pollID string, vote poll.Vote) error {
-	return r.withTx(func(tx *sql.Tx) error {
+func (r *PollRepository) AddVote(ctx context.Context, pollID string, vote poll.Vote) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
 		// Get the internal poll row ID.
 		var rowID int64
-		err := tx.QueryRow("SELECT id FROM polls WHERE public_id = ?", pollID).Scan(&rowID)
+		err := tx.QueryRowContext(ctx, "SELECT id FROM polls WHERE public_id = ?", pollID).Scan(&rowID)
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("poll not found")
 		}
@@ -238,41 +362,56 @@ func (r *PollRepository) AddVote(pollID string, vote poll.Vote) error {
 			return fmt.Errorf("query poll id: %w", err)
 		}
 
-		// Insert the vote.
-		res, err := tx.Exec("INSERT INTO votes (poll_id, name) VALUES (?, ?)", rowID, vote.Name)
-		if err != nil {
-			return fmt.Errorf("insert vote: %w", err)
-		}
-		voteID, err := res.LastInsertId()
-		if err != nil {
-			return fmt.Errorf("last insert id: %w", err)
-		}
-
-		optionIDByLabel, err := loadOptionIDsByLabel(tx, rowID)
+		optionIDByLabel, err := loadOptionIDsByLabel(ctx, tx, rowID)
 		if err != nil {
 			return err
 		}
+		return insertVote(ctx, tx, rowID, optionIDByLabel, vote)
+	})
+}
 
-		// Insert vote responses.
-		for label, value := range vote.Responses {
-			optID, ok := optionIDByLabel[label]
-			if !ok {
-				continue
-			}
-			_, err := tx.Exec(
-				"INSERT INTO vote_responses (vote_id, option_id, available) VALUES (?, ?, ?)",
-				voteID, optID, availableStringToInt(value),
-			)
-			if err != nil {
-				return fmt.Errorf("insert response for %q: %w", label, err)
-			}
+// insertVote inserts vote and its responses for the poll with the given row
+// ID. The vote is stamped with the current time if VotedAt is zero.
+func insertVote(ctx context.Context, tx *sql.Tx, pollRowID int64, optionIDByLabel map[string]int64, vote poll.Vote) error {
+	votedAt := vote.VotedAt
+	if votedAt.IsZero() {
+		votedAt = time.Now()
+	}
+	var editedAt any
+	if !vote.EditedAt.IsZero() {
+		editedAt = vote.EditedAt.UTC().Format(sqliteTimeLayout)
+	}
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO votes (poll_id, name, voted_at, edited_at) VALUES (?, ?, ?, ?)",
+		pollRowID, vote.Name, votedAt.UTC().Format(sqliteTimeLayout), editedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert vote: %w", err)
+	}
+	voteID, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("last insert id: %w", err)
+	}
+
+	// Insert vote responses.
+	for label, value := range vote.Responses {
+		optID, ok := optionIDByLabel[label]
+		if !ok {
+			continue
 		}
-		return nil
-	})
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO vote_responses (vote_id, option_id, available) VALUES (?, ?, ?)",
+			voteID, optID, availableStringToInt(value),
+		)
+		if err != nil {
+			return fmt.Errorf("insert response for %q: %w", label, err)
+		}
+	}
+	return nil
 }
 
-func (r *PollRepository) Delete(pollID string) error {
-	res, err := r.db.Exec("DELETE FROM polls WHERE public_id = ?", pollID)
+func (r *PollRepository) Delete(ctx context.Context, pollID string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM polls WHERE public_id = ?", pollID)
 	if err != nil {
 		return fmt.Errorf("delete poll: %w", err)
 	}
@@ -286,11 +425,11 @@ func (r *PollRepository) Delete(pollID string) error {
 	return nil
 }
 
-func (r *PollRepository) UpdateVote(pollID string, oldName string, vote poll.Vote) error {
-	return r.withTx(func(tx *sql.Tx) error {
+func (r *PollRepository) UpdateVote(ctx context.Context, pollID string, oldName string, vote poll.Vote) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
 		// Get the internal poll row ID.
 		var rowID int64
-		err := tx.QueryRow("SELECT id FROM polls WHERE public_id = ?", pollID).Scan(&rowID)
+		err := tx.QueryRowContext(ctx, "SELECT id FROM polls WHERE public_id = ?", pollID).Scan(&rowID)
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("poll not found")
 		}
@@ -300,7 +439,7 @@ func (r *PollRepository) UpdateVote(pollID string, oldName string, vote poll.Vot
 
 		// Find the existing vote row ID (preserves id and voted_at).
 		var voteID int64
-		err = tx.QueryRow("SELECT id FROM votes WHERE poll_id = ? AND name = ?", rowID, oldName).Scan(&voteID)
+		err = tx.QueryRowContext(ctx, "SELECT id FROM votes WHERE poll_id = ? AND name = ?", rowID, oldName).Scan(&voteID)
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("vote not found")
 		}
@@ -309,12 +448,12 @@ func (r *PollRepository) UpdateVote(pollID string, oldName string, vote poll.Vot
 		}
 
 		// Update the voter name and mark as edited, preserving id and voted_at.
-		_, err = tx.Exec("UPDATE votes SET name = ?, edited_at = datetime('now') WHERE id = ?", vote.Name, voteID)
+		_, err = tx.ExecContext(ctx, "UPDATE votes SET name = ?, edited_at = datetime('now') WHERE id = ?", vote.Name, voteID)
 		if err != nil {
 			return fmt.Errorf("update vote: %w", err)
 		}
 
-		optionIDByLabel, err := loadOptionIDsByLabel(tx, rowID)
+		optionIDByLabel, err := loadOptionIDsByLabel(ctx, tx, rowID)
 		if err != nil {
 			return err
 		}
@@ -325,7 +464,7 @@ func (r *PollRepository) UpdateVote(pollID string, oldName string, vote poll.Vot
 			if !ok {
 				continue
 			}
-			_, err := tx.Exec(
+			_, err := tx.ExecContext(ctx,
 				"INSERT INTO vote_responses (vote_id, option_id, available) VALUES (?, ?, ?) ON CONFLICT(vote_id, option_id) DO UPDATE SET available = excluded.available",
 				voteID, optID, availableStringToInt(value),
 			)
@@ -337,6 +476,89 @@ func (r *PollRepository) UpdateVote(pollID string, oldName string, vote poll.Vot
 	})
 }
 
+func (r *PollRepository) ListExpired(ctx context.Context, cutoff time.Time, basis string, limit int) ([]poll.PollSummary, error) {
+	// Timestamps are normalised with datetime() because polls.created_at is
+	// stored as RFC 3339 while vote timestamps use SQLite's own format.
+	clock := "datetime(p.created_at)"
+	switch basis {
+	case poll.RetainSinceLastVote:
+		clock = "COALESCE(MAX(datetime(COALESCE(v.edited_at, v.voted_at))), datetime(p.created_at))"
+	case poll.RetainSinceClosed:
+		clock = "datetime(p.closed_at)"
+	}
+	having := clock + " < datetime(?)"
+	if basis == poll.RetainSinceClosed {
+		// Open polls are still taking votes and never expire.
+		having = "p.closed_at IS NOT NULL AND " + having
+	}
+	query := `SELECT p.public_id, p.title, p.created_at, p.closed_at, COUNT(v.id), MAX(COALESCE(v.edited_at, v.voted_at))
+		FROM polls p LEFT JOIN votes v ON v.poll_id = p.id
+		GROUP BY p.id
+		HAVING ` + having + `
+		ORDER BY ` + clock + `, p.id
+		LIMIT ?`
+
+	rows, err := r.db.QueryContext(ctx, query, cutoff.UTC().Format(sqliteTimeLayout), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query expired polls: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var expired []poll.PollSummary
+	for rows.Next() {
+		s, err := scanSummary(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan expired poll: %w", err)
+		}
+		expired = append(expired, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate expired polls: %w", err)
+	}
+	return expired, nil
+}
+
+// scanSummary scans a row of public ID, title, created_at, closed_at, vote
+// count and last vote time.
+func scanSummary(rows *sql.Rows) (poll.PollSummary, error) {
+	var s poll.PollSummary
+	var createdAt string
+	var closedAt, lastVote sql.NullString
+	if err := rows.Scan(&s.ID, &s.Title, &createdAt, &closedAt, &s.Votes, &lastVote); err != nil {
+		return s, err
+	}
+	s.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	if closedAt.Valid {
+		s.ClosedAt, _ = time.Parse(time.RFC3339, closedAt.String)
+	}
+	if lastVote.Valid {
+		s.LastVoteAt, _ = time.Parse(sqliteTimeLayout, lastVote.String)
+	}
+	return s, nil
+}
+
+func (r *PollRepository) DeleteMany(ctx context.Context, pollIDs []string) (int, error) {
+	var deleted int
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		for _, id := range pollIDs {
+			res, err := tx.ExecContext(ctx, "DELETE FROM polls WHERE public_id = ?", id)
+			if err != nil {
+				return fmt.Errorf("delete poll %s: %w", id, err)
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("rows affected: %w", err)
+			}
+			deleted += int(n)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
 // availableStringToInt maps response strings to DB integers: "yes"->1, "maybe"->2, else->0.
 func availableStringToInt(s string) int {
 	switch s {
@@ -1,7 +1,11 @@
 package sqlite
 
 import (
+	"context"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"meetkat/internal/poll"
 )
@@ -21,18 +25,18 @@ func TestCreateAndGet(t *testing.T) {
 
 	p := &poll.Poll{
 		ID:          "abc12345",
-		AdminID:     "adm12345",
+		AdminHash:   "adm12345",
 		Title:       "Dinner",
 		Description: "Pick a day",
 		AnswerMode:  "yn",
 		Options:     []string{"Mon", "Tue", "Wed"},
 	}
 
-	if err := repo.Create(p); err != nil {
+	if err := repo.Create(context.Background(), p); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	got, err := repo.GetByPublicID("abc12345")
+	got, err := repo.GetByPublicID(context.Background(), "abc12345")
 	if err != nil {
 		t.Fatalf("get: %v", err)
 	}
@@ -45,8 +49,8 @@ func TestCreateAndGet(t *testing.T) {
 	if got.Description != "Pick a day" {
 		t.Errorf("description: got %q, want %q", got.Description, "Pick a day")
 	}
-	if got.AdminID != "adm12345" {
-		t.Errorf("admin_id: got %q, want %q", got.AdminID, "adm12345")
+	if got.AdminHash != "adm12345" {
+		t.Errorf("admin_hash: got %q, want %q", got.AdminHash, "adm12345")
 	}
 	if got.AnswerMode != "yn" {
 		t.Errorf("answer_mode: got %q, want %q", got.AnswerMode, "yn")
@@ -70,16 +74,16 @@ func TestCreateAndGetYMN(t *testing.T) {
 
 	p := &poll.Poll{
 		ID:         "ymn12345",
-		AdminID:    "adm_ymn1",
+		AdminHash:  "adm_ymn1",
 		Title:      "YMN Poll",
 		AnswerMode: "ymn",
 		Options:    []string{"A", "B"},
 	}
-	if err := repo.Create(p); err != nil {
+	if err := repo.Create(context.Background(), p); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	err := repo.AddVote("ymn12345", poll.Vote{
+	err := repo.AddVote(context.Background(), "ymn12345", poll.Vote{
 		Name:      "Alice",
 		Responses: map[string]string{"A": "yes", "B": "maybe"},
 	})
@@ -87,7 +91,7 @@ func TestCreateAndGetYMN(t *testing.T) {
 		t.Fatalf("add vote: %v", err)
 	}
 
-	got, err := repo.GetByPublicID("ymn12345")
+	got, err := repo.GetByPublicID(context.Background(), "ymn12345")
 	if err != nil {
 		t.Fatalf("get: %v", err)
 	}
@@ -109,7 +113,7 @@ func TestCreateAndGetYMN(t *testing.T) {
 func TestGetNotFound(t *testing.T) {
 	repo := openTestDB(t)
 
-	got, err := repo.GetByPublicID("nonexistent")
+	got, err := repo.GetByPublicID(context.Background(), "nonexistent")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -118,23 +122,23 @@ func TestGetNotFound(t *testing.T) {
 	}
 }
 
-func TestGetByAdminID(t *testing.T) {
+func TestGetByAdminHash(t *testing.T) {
 	repo := openTestDB(t)
 
 	p := &poll.Poll{
 		ID:          "pub12345",
-		AdminID:     "adm99999",
+		AdminHash:   "adm99999",
 		Title:       "Admin test",
 		Description: "",
 		Options:     []string{"A"},
 	}
-	if err := repo.Create(p); err != nil {
+	if err := repo.Create(context.Background(), p); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	got, err := repo.GetByAdminID("adm99999")
+	got, err := repo.GetByAdminHash(context.Background(), "adm99999")
 	if err != nil {
-		t.Fatalf("get by admin id: %v", err)
+		t.Fatalf("get by admin hash: %v", err)
 	}
 	if got == nil {
 		t.Fatal("expected poll, got nil")
@@ -142,15 +146,65 @@ func TestGetByAdminID(t *testing.T) {
 	if got.ID != "pub12345" {
 		t.Errorf("public id: got %q, want %q", got.ID, "pub12345")
 	}
-	if got.AdminID != "adm99999" {
-		t.Errorf("admin id: got %q, want %q", got.AdminID, "adm99999")
+	if got.AdminHash != "adm99999" {
+		t.Errorf("admin hash: got %q, want %q", got.AdminHash, "adm99999")
 	}
 }
 
-func TestGetByAdminIDNotFound(t *testing.T) {
+func TestSetAdminHash(t *testing.T) {
+	repo := openTestDB(t)
+	if err := repo.Create(context.Background(), &poll.Poll{ID: "pub_rst1", AdminHash: "old_hash", Title: "Reset", Options: []string{"A"}}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := repo.SetAdminHash(context.Background(), "pub_rst1", "new_hash"); err != nil {
+		t.Fatalf("set admin hash: %v", err)
+	}
+	if got, _ := repo.GetByAdminHash(context.Background(), "old_hash"); got != nil {
+		t.Error("expected the old hash to no longer match")
+	}
+	if got, _ := repo.GetByAdminHash(context.Background(), "new_hash"); got == nil || got.ID != "pub_rst1" {
+		t.Errorf("expected the new hash to match, got %+v", got)
+	}
+	if err := repo.SetAdminHash(context.Background(), "nonexistent", "x"); err == nil {
+		t.Error("expected error for nonexistent poll")
+	}
+}
+
+func TestSetClosedAt(t *testing.T) {
+	repo := openTestDB(t)
+	p := &poll.Poll{ID: "close123", AdminHash: "adm_close", Title: "Close me", Options: []string{"A"}, CreatedAt: time.Now()}
+	if err := repo.Create(context.Background(), p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	closedAt := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+	if err := repo.SetClosedAt(context.Background(), "close123", closedAt); err != nil {
+		t.Fatalf("set closed_at: %v", err)
+	}
+	got, _ := repo.GetByPublicID(context.Background(), "close123")
+	if !got.ClosedAt.Equal(closedAt) {
+		t.Errorf("closed_at: got %v, want %v", got.ClosedAt, closedAt)
+	}
+	if got.ResultsVisibility != poll.ResultsAlways {
+		t.Errorf("results visibility: got %q, want the default", got.ResultsVisibility)
+	}
+
+	if err := repo.SetClosedAt(context.Background(), "close123", time.Time{}); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got, _ := repo.GetByPublicID(context.Background(), "close123"); got.Closed() {
+		t.Errorf("expected reopened poll, got closed_at %v", got.ClosedAt)
+	}
+	if err := repo.SetClosedAt(context.Background(), "nope", closedAt); err == nil {
+		t.Error("expected error for nonexistent poll")
+	}
+}
+
+func TestGetByAdminHashNotFound(t *testing.T) {
 	repo := openTestDB(t)
 
-	got, err := repo.GetByAdminID("nonexistent")
+	got, err := repo.GetByAdminHash(context.Background(), "nonexistent")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -163,16 +217,16 @@ func TestAddVote(t *testing.T) {
 	repo := openTestDB(t)
 
 	p := &poll.Poll{
-		ID:      "vote1234",
-		AdminID: "adm_vote",
-		Title:   "Lunch",
-		Options: []string{"Mon", "Tue"},
+		ID:        "vote1234",
+		AdminHash: "adm_vote",
+		Title:     "Lunch",
+		Options:   []string{"Mon", "Tue"},
 	}
-	if err := repo.Create(p); err != nil {
+	if err := repo.Create(context.Background(), p); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	err := repo.AddVote("vote1234", poll.Vote{
+	err := repo.AddVote(context.Background(), "vote1234", poll.Vote{
 		Name:      "Alice",
 		Responses: map[string]string{"Mon": "yes", "Tue": "no"},
 	})
@@ -180,7 +234,7 @@ func TestAddVote(t *testing.T) {
 		t.Fatalf("add vote: %v", err)
 	}
 
-	got, err := repo.GetByPublicID("vote1234")
+	got, err := repo.GetByPublicID(context.Background(), "vote1234")
 	if err != nil {
 		t.Fatalf("get: %v", err)
 	}
@@ -205,7 +259,7 @@ func TestAddVote(t *testing.T) {
 func TestAddVoteNonexistentPoll(t *testing.T) {
 	repo := openTestDB(t)
 
-	err := repo.AddVote("nope", poll.Vote{Name: "Bob", Responses: map[string]string{}})
+	err := repo.AddVote(context.Background(), "nope", poll.Vote{Name: "Bob", Responses: map[string]string{}})
 	if err == nil {
 		t.Fatal("expected error for nonexistent poll")
 	}
@@ -215,23 +269,23 @@ func TestRemoveVote(t *testing.T) {
 	repo := openTestDB(t)
 
 	p := &poll.Poll{
-		ID:      "rmvote12",
-		AdminID: "adm_rmv1",
-		Title:   "Remove test",
-		Options: []string{"Mon", "Tue"},
+		ID:        "rmvote12",
+		AdminHash: "adm_rmv1",
+		Title:     "Remove test",
+		Options:   []string{"Mon", "Tue"},
 	}
-	if err := repo.Create(p); err != nil {
+	if err := repo.Create(context.Background(), p); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	_ = repo.AddVote("rmvote12", poll.Vote{Name: "Alice", Responses: map[string]string{"Mon": "yes"}})
-	_ = repo.AddVote("rmvote12", poll.Vote{Name: "Bob", Responses: map[string]string{"Tue": "yes"}})
+	_ = repo.AddVote(context.Background(), "rmvote12", poll.Vote{Name: "Alice", Responses: map[string]string{"Mon": "yes"}})
+	_ = repo.AddVote(context.Background(), "rmvote12", poll.Vote{Name: "Bob", Responses: map[string]string{"Tue": "yes"}})
 
-	if err := repo.RemoveVote("rmvote12", "Alice"); err != nil {
+	if err := repo.RemoveVote(context.Background(), "rmvote12", "Alice"); err != nil {
 		t.Fatalf("remove vote: %v", err)
 	}
 
-	got, _ := repo.GetByPublicID("rmvote12")
+	got, _ := repo.GetByPublicID(context.Background(), "rmvote12")
 	if len(got.Votes) != 1 {
 		t.Fatalf("votes: got %d, want 1", len(got.Votes))
 	}
@@ -244,16 +298,16 @@ func TestRemoveVoteNotFound(t *testing.T) {
 	repo := openTestDB(t)
 
 	p := &poll.Poll{
-		ID:      "rmvnf123",
-		AdminID: "adm_rmvn",
-		Title:   "Remove NF",
-		Options: []string{"A"},
+		ID:        "rmvnf123",
+		AdminHash: "adm_rmvn",
+		Title:     "Remove NF",
+		Options:   []string{"A"},
 	}
-	if err := repo.Create(p); err != nil {
+	if err := repo.Create(context.Background(), p); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	err := repo.RemoveVote("rmvnf123", "Nobody")
+	err := repo.RemoveVote(context.Background(), "rmvnf123", "Nobody")
 	if err == nil {
 		t.Fatal("expected error for nonexistent voter")
 	}
@@ -263,19 +317,19 @@ func TestMultipleVotes(t *testing.T) {
 	repo := openTestDB(t)
 
 	p := &poll.Poll{
-		ID:      "multi123",
-		AdminID: "adm_mult",
-		Title:   "Sprint",
-		Options: []string{"A", "B"},
+		ID:        "multi123",
+		AdminHash: "adm_mult",
+		Title:     "Sprint",
+		Options:   []string{"A", "B"},
 	}
-	if err := repo.Create(p); err != nil {
+	if err := repo.Create(context.Background(), p); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	_ = repo.AddVote("multi123", poll.Vote{Name: "Alice", Responses: map[string]string{"A": "yes", "B": "yes"}})
-	_ = repo.AddVote("multi123", poll.Vote{Name: "Bob", Responses: map[string]string{"A": "yes", "B": "no"}})
+	_ = repo.AddVote(context.Background(), "multi123", poll.Vote{Name: "Alice", Responses: map[string]string{"A": "yes", "B": "yes"}})
+	_ = repo.AddVote(context.Background(), "multi123", poll.Vote{Name: "Bob", Responses: map[string]string{"A": "yes", "B": "no"}})
 
-	got, _ := repo.GetByPublicID("multi123")
+	got, _ := repo.GetByPublicID(context.Background(), "multi123")
 	if len(got.Votes) != 2 {
 		t.Fatalf("votes: got %d, want 2", len(got.Votes))
 	}
@@ -291,21 +345,21 @@ func TestUpdateVotePreservesPosition(t *testing.T) {
 	repo := openTestDB(t)
 
 	p := &poll.Poll{
-		ID:      "upd12345",
-		AdminID: "adm_upd1",
-		Title:   "Update test",
-		Options: []string{"Mon", "Tue"},
+		ID:        "upd12345",
+		AdminHash: "adm_upd1",
+		Title:     "Update test",
+		Options:   []string{"Mon", "Tue"},
 	}
-	if err := repo.Create(p); err != nil {
+	if err := repo.Create(context.Background(), p); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	_ = repo.AddVote("upd12345", poll.Vote{Name: "Alice", Responses: map[string]string{"Mon": "yes", "Tue": "no"}})
-	_ = repo.AddVote("upd12345", poll.Vote{Name: "Bob", Responses: map[string]string{"Mon": "no", "Tue": "yes"}})
-	_ = repo.AddVote("upd12345", poll.Vote{Name: "Carol", Responses: map[string]string{"Mon": "yes", "Tue": "yes"}})
+	_ = repo.AddVote(context.Background(), "upd12345", poll.Vote{Name: "Alice", Responses: map[string]string{"Mon": "yes", "Tue": "no"}})
+	_ = repo.AddVote(context.Background(), "upd12345", poll.Vote{Name: "Bob", Responses: map[string]string{"Mon": "no", "Tue": "yes"}})
+	_ = repo.AddVote(context.Background(), "upd12345", poll.Vote{Name: "Carol", Responses: map[string]string{"Mon": "yes", "Tue": "yes"}})
 
 	// Update Bob's name and responses.
-	err := repo.UpdateVote("upd12345", "Bob", poll.Vote{
+	err := repo.UpdateVote(context.Background(), "upd12345", "Bob", poll.Vote{
 		Name:      "Bobby",
 		Responses: map[string]string{"Mon": "yes", "Tue": "yes"},
 	})
@@ -313,7 +367,7 @@ func TestUpdateVotePreservesPosition(t *testing.T) {
 		t.Fatalf("update vote: %v", err)
 	}
 
-	got, _ := repo.GetByPublicID("upd12345")
+	got, _ := repo.GetByPublicID(context.Background(), "upd12345")
 	if len(got.Votes) != 3 {
 		t.Fatalf("votes: got %d, want 3", len(got.Votes))
 	}
@@ -339,16 +393,16 @@ func TestUpdateVoteNotFound(t *testing.T) {
 	repo := openTestDB(t)
 
 	p := &poll.Poll{
-		ID:      "updnf123",
-		AdminID: "adm_unf1",
-		Title:   "Update NF",
-		Options: []string{"A"},
+		ID:        "updnf123",
+		AdminHash: "adm_unf1",
+		Title:     "Update NF",
+		Options:   []string{"A"},
 	}
-	if err := repo.Create(p); err != nil {
+	if err := repo.Create(context.Background(), p); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	err := repo.UpdateVote("updnf123", "Nobody", poll.Vote{Name: "X", Responses: map[string]string{"A": "yes"}})
+	err := repo.UpdateVote(context.Background(), "updnf123", "Nobody", poll.Vote{Name: "X", Responses: map[string]string{"A": "yes"}})
 	if err == nil {
 		t.Fatal("expected error for nonexistent voter")
 	}
@@ -364,16 +418,16 @@ func TestServiceWithSQLiteRepository(t *testing.T) {
 	repo := NewPollRepository(db)
 	svc := poll.NewService(repo)
 
-	p, err := svc.Create("End-to-end", "Test full flow", "yn", []string{"X", "Y"})
+	p, err := svc.Create(context.Background(), "End-to-end", "Test full flow", "yn", []string{"X", "Y"}, poll.Settings{})
 	if err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	if err := svc.AddVote(p.ID, "Carol", map[string]string{"X": "yes", "Y": "no"}); err != nil {
+	if err := svc.AddVote(context.Background(), p.ID, "Carol", map[string]string{"X": "yes", "Y": "no"}); err != nil {
 		t.Fatalf("add vote: %v", err)
 	}
 
-	got, err := svc.Get(p.ID)
+	got, err := svc.Get(context.Background(), p.ID)
 	if err != nil {
 		t.Fatalf("get: %v", err)
 	}
@@ -395,3 +449,186 @@ func TestServiceWithSQLiteRepository(t *testing.T) {
 		t.Errorf("totals[Y].Yes: got %d, want 0", totals["Y"].Yes)
 	}
 }
+
+func TestListExpiredAndDeleteMany(t *testing.T) {
+	repo := openTestDB(t)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, p := range []*poll.Poll{
+		{ID: "exp_old1", AdminHash: "adm_old1", Title: "Old, recent vote", Options: []string{"A"}, CreatedAt: now.AddDate(0, 0, -40)},
+		{ID: "exp_old2", AdminHash: "adm_old2", Title: "Old, no votes", Options: []string{"A"}, CreatedAt: now.AddDate(0, 0, -35)},
+		{ID: "exp_new1", AdminHash: "adm_new1", Title: "New", Options: []string{"A"}, CreatedAt: now.AddDate(0, 0, -1)},
+	} {
+		if err := repo.Create(context.Background(), p); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	_ = repo.AddVote(context.Background(), "exp_old1", poll.Vote{Name: "Alice", Responses: map[string]string{"A": "yes"}, VotedAt: now.AddDate(0, 0, -2)})
+
+	cutoff := now.AddDate(0, 0, -30)
+	byCreation, err := repo.ListExpired(context.Background(), cutoff, poll.RetainSinceCreated, 10)
+	if err != nil {
+		t.Fatalf("list by creation: %v", err)
+	}
+	if len(byCreation) != 2 || byCreation[0].ID != "exp_old1" || byCreation[1].ID != "exp_old2" {
+		t.Fatalf("by creation: got %+v, want exp_old1, exp_old2", byCreation)
+	}
+	if byCreation[0].Votes != 1 || !byCreation[0].LastVoteAt.Equal(now.AddDate(0, 0, -2)) {
+		t.Errorf("summary: got %+v, want 1 vote at %v", byCreation[0], now.AddDate(0, 0, -2))
+	}
+
+	byVote, err := repo.ListExpired(context.Background(), cutoff, poll.RetainSinceLastVote, 10)
+	if err != nil {
+		t.Fatalf("list by last vote: %v", err)
+	}
+	if len(byVote) != 1 || byVote[0].ID != "exp_old2" {
+		t.Fatalf("by last vote: got %+v, want exp_old2", byVote)
+	}
+
+	// Open polls never expire by closing, however old they are; closed
+	// polls count from when they were closed.
+	byClosing, err := repo.ListExpired(context.Background(), cutoff, poll.RetainSinceClosed, 10)
+	if err != nil {
+		t.Fatalf("list by closing: %v", err)
+	}
+	if len(byClosing) != 0 {
+		t.Fatalf("by closing: got %+v, want no open polls", byClosing)
+	}
+	if err := repo.SetClosedAt(context.Background(), "exp_old1", now.AddDate(0, 0, -3)); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := repo.SetClosedAt(context.Background(), "exp_old2", now.AddDate(0, 0, -31)); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	byClosing, err = repo.ListExpired(context.Background(), cutoff, poll.RetainSinceClosed, 10)
+	if err != nil {
+		t.Fatalf("list by closing: %v", err)
+	}
+	if len(byClosing) != 1 || byClosing[0].ID != "exp_old2" || !byClosing[0].ClosedAt.Equal(now.AddDate(0, 0, -31)) {
+		t.Fatalf("by closing: got %+v, want exp_old2 closed 31 days ago", byClosing)
+	}
+
+	limited, err := repo.ListExpired(context.Background(), cutoff, poll.RetainSinceCreated, 1)
+	if err != nil {
+		t.Fatalf("list limited: %v", err)
+	}
+	if len(limited) != 1 {
+		t.Errorf("limit: got %d, want 1", len(limited))
+	}
+
+	n, err := repo.DeleteMany(context.Background(), []string{"exp_old1", "exp_old2", "missing1"})
+	if err != nil {
+		t.Fatalf("delete many: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d, want 2", n)
+	}
+	if got, _ := repo.GetByPublicID(context.Background(), "exp_new1"); got == nil {
+		t.Error("expected new poll to survive")
+	}
+}
+
+func TestCreateWithVotesPreservesTimestamps(t *testing.T) {
+	repo := openTestDB(t)
+	votedAt := time.Date(2025, 5, 1, 9, 30, 0, 0, time.UTC)
+	editedAt := time.Date(2025, 5, 2, 10, 0, 0, 0, time.UTC)
+
+	p := &poll.Poll{
+		ID:        "impv1234",
+		AdminHash: "adm_impv",
+		Title:     "Imported",
+		Options:   []string{"A", "B"},
+		CreatedAt: time.Date(2025, 4, 30, 8, 0, 0, 0, time.UTC),
+
+		PasswordHash:      "$2a$10$hash",
+		ResultsVisibility: poll.ResultsAfterClose,
+		NameVisibility:    poll.NamesAdminOnly,
+		Votes: []poll.Vote{
+			{Name: "Alice", Responses: map[string]string{"A": "yes", "B": "no"}, VotedAt: votedAt, EditedAt: editedAt},
+			{Name: "Bob", Responses: map[string]string{"B": "yes"}, VotedAt: votedAt.Add(time.Hour)},
+		},
+	}
+	if err := repo.Create(context.Background(), p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	got, err := repo.GetByPublicID(context.Background(), "impv1234")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.PasswordHash != "$2a$10$hash" {
+		t.Errorf("password hash: got %q", got.PasswordHash)
+	}
+	if got.ResultsVisibility != poll.ResultsAfterClose || got.Closed() {
+		t.Errorf("results visibility: got %q, closed %v", got.ResultsVisibility, got.ClosedAt)
+	}
+	if got.NameVisibility != poll.NamesAdminOnly {
+		t.Errorf("name visibility: got %q", got.NameVisibility)
+	}
+	if len(got.Votes) != 2 {
+		t.Fatalf("votes: got %d, want 2", len(got.Votes))
+	}
+	if !got.Votes[0].VotedAt.Equal(votedAt) || !got.Votes[0].EditedAt.Equal(editedAt) {
+		t.Errorf("Alice timestamps: got %v / %v", got.Votes[0].VotedAt, got.Votes[0].EditedAt)
+	}
+	if !got.Votes[1].EditedAt.IsZero() {
+		t.Errorf("Bob edited_at: got %v, want zero", got.Votes[1].EditedAt)
+	}
+	if got.Votes[0].Responses["A"] != "yes" || got.Votes[1].Responses["B"] != "yes" {
+		t.Errorf("responses not preserved: %+v", got.Votes)
+	}
+
+	ids, err := repo.ListIDs(context.Background())
+	if err != nil {
+		t.Fatalf("list ids: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "impv1234" {
+		t.Errorf("ids: got %v", ids)
+	}
+}
+
+func TestListSummaries(t *testing.T) {
+	repo := openTestDB(t)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, title := range []string{"Team lunch", "100% off SALE", "Board meeting", "Lunch_break"} {
+		p := &poll.Poll{ID: fmt.Sprintf("sum_%d", i), AdminHash: fmt.Sprintf("adm_sum_%d", i), Title: title, Options: []string{"A"}, CreatedAt: now.AddDate(0, 0, i)}
+		if err := repo.Create(context.Background(), p); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	_ = repo.AddVote(context.Background(), "sum_0", poll.Vote{Name: "Alice", Responses: map[string]string{"A": "yes"}, VotedAt: now})
+
+	ids := func(query string, offset, limit int) []string {
+		t.Helper()
+		polls, err := repo.ListSummaries(context.Background(), query, offset, limit)
+		if err != nil {
+			t.Fatalf("ListSummaries(%q): %v", query, err)
+		}
+		var ids []string
+		for _, p := range polls {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+	for _, tt := range []struct {
+		query         string
+		offset, limit int
+		want          []string
+	}{
+		{"", 0, 10, []string{"sum_3", "sum_2", "sum_1", "sum_0"}},
+		{"", 1, 2, []string{"sum_2", "sum_1"}},
+		{"LUNCH", 0, 10, []string{"sum_3", "sum_0"}},
+		{"%", 0, 10, []string{"sum_1"}},
+		{"h_b", 0, 10, []string{"sum_3"}},
+		{"nothing", 0, 10, nil},
+	} {
+		if got := ids(tt.query, tt.offset, tt.limit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListSummaries(%q, %d, %d) = %v, want %v", tt.query, tt.offset, tt.limit, got, tt.want)
+		}
+	}
+
+	polls, _ := repo.ListSummaries(context.Background(), "team", 0, 1)
+	if len(polls) != 1 || polls[0].Votes != 1 || !polls[0].CreatedAt.Equal(now) {
+		t.Errorf("summary = %+v, want one vote and creation time", polls)
+	}
+}
@@ -2,38 +2,22 @@ package sqlite
 
 import (
 	"database/sql"
-	"embed"
 	"fmt"
-	"sort"
-	"strings"
 
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
 	_ "modernc.org/sqlite"
 )
 
-//go:embed migrations/*.sql
-var migrationFS embed.FS
-
 // Open opens a SQLite database at the given path, applies PRAGMAs, and runs
 // any pending migrations. Use ":memory:" for an in-memory database.
 func Open(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", dsn)
+	db, err := Connect(dsn)
 	if err != nil {
-		return nil, fmt.Errorf("open database: %w", err)
+		return nil, err
 	}
 
-	pragmas := []string{
-		"PRAGMA journal_mode=WAL",
-		"PRAGMA foreign_keys=ON",
-		"PRAGMA busy_timeout=5000",
-	}
-	for _, p := range pragmas {
-		if _, err := db.Exec(p); err != nil {
-			_ = db.Close()
-			return nil, fmt.Errorf("exec %q: %w", p, err)
-		}
-	}
-
-	if err := migrate(db); err != nil {
+	if _, err := NewMigrator(db, nil).Up(false); err != nil {
 		_ = db.Close()
 		return nil, fmt.Errorf("migrate: %w", err)
 	}
@@ -41,64 +25,36 @@ func Open(dsn string) (*sql.DB, error) {
 	return db, nil
 }
 
-func migrate(db *sql.DB) error {
-	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
-		version TEXT PRIMARY KEY
-	)`)
+// Connect opens a SQLite database and applies PRAGMAs without touching the
+// schema. Callers are responsible for checking or applying migrations.
+// Every statement is traced through the global OpenTelemetry tracer provider,
+// which is a no-op unless tracing is enabled.
+func Connect(dsn string) (*sql.DB, error) {
+	db, err := otelsql.Open("sqlite", dsn,
+		otelsql.WithAttributes(attribute.String("db.system", "sqlite")),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			OmitConnectorConnect: true,
+		}),
+	)
 	if err != nil {
-		return fmt.Errorf("create schema_migrations: %w", err)
+		return nil, fmt.Errorf("open database: %w", err)
 	}
 
-	entries, err := migrationFS.ReadDir("migrations")
-	if err != nil {
-		return fmt.Errorf("read migrations dir: %w", err)
+	pragmas := []string{
+		"PRAGMA journal_mode=WAL",
+		"PRAGMA foreign_keys=ON",
+		"PRAGMA busy_timeout=5000",
 	}
-
-	// Sort by filename to ensure correct order.
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Name() < entries[j].Name()
-	})
-
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
-			continue
-		}
-
-		version := strings.TrimSuffix(entry.Name(), ".sql")
-
-		var exists int
-		err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", version).Scan(&exists)
-		if err != nil {
-			return fmt.Errorf("check migration %s: %w", version, err)
-		}
-		if exists > 0 {
-			continue
-		}
-
-		content, err := migrationFS.ReadFile("migrations/" + entry.Name())
-		if err != nil {
-			return fmt.Errorf("read migration %s: %w", entry.Name(), err)
-		}
-
-		tx, err := db.Begin()
-		if err != nil {
-			return fmt.Errorf("begin tx for %s: %w", version, err)
-		}
-
-		if _, err := tx.Exec(string(content)); err != nil {
-			_ = tx.Rollback()
-			return fmt.Errorf("exec migration %s: %w", version, err)
-		}
-
-		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", version); err != nil {
-			_ = tx.Rollback()
-			return fmt.Errorf("record migration %s: %w", version, err)
-		}
-
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("commit migration %s: %w", version, err)
+	for _, p := range pragmas {
+		if _, err := db.Exec(p); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("exec %q: %w", p, err)
 		}
 	}
 
-	return nil
+	return db, nil
 }
@@ -0,0 +1,137 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader serves a certificate loaded from a cert/key file pair and swaps
+// it in place when the files are reloaded, so new TLS handshakes use the new
+// certificate while established connections are unaffected.
+type Reloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time // newest modification time of the two files at load
+}
+
+// NewReloader loads the certificate and key, failing if they are unusable.
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On error the previous certificate stays in
+// use.
+func (r *Reloader) Reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// TLSConfig returns a server TLS configuration using the reloadable
+// certificate.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// Watch checks the files every interval and reloads them after they change,
+// until ctx is cancelled. Tools like certbot replace the two files one after
+// the other, so a failed reload is retried on the next tick.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		modTime, err := r.filesModTime()
+		if err != nil {
+			slog.Warn("check TLS certificate", "err", err)
+			continue
+		}
+		r.mu.RLock()
+		changed := !modTime.Equal(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			slog.Warn("reload TLS certificate", "err", err)
+			continue
+		}
+		slog.Info("reloaded TLS certificate", "cert", r.certFile)
+	}
+}
+
+func (r *Reloader) filesModTime() (time.Time, error) {
+	var newest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("stat %s: %w", path, err)
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
+
+// RedirectHandler redirects every request to the same path over HTTPS. The
+// host is taken from baseURL if set, otherwise from the request's Host header
+// with httpsPort.
+func RedirectHandler(httpsPort, baseURL string) http.Handler {
+	var target string
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		target = "https://" + u.Host
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		target := target
+		if target == "" {
+			host := req.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if httpsPort != "443" {
+				host = net.JoinHostPort(host, httpsPort)
+			}
+			target = "https://" + host
+		}
+		http.Redirect(w, req, target+req.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
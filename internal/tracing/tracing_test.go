@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"meetkat/internal/middleware"
+	"meetkat/internal/poll"
+	"meetkat/internal/sqlite"
+
+	"github.com/gin-gonic/gin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRequestServiceAndSQLSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := Install(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = tp.Shutdown(t.Context()) })
+
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	svc := poll.NewService(sqlite.NewPollRepository(db))
+	p, err := svc.Create(t.Context(), "Traced", "", "yn", []string{"A"}, poll.Settings{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Tracing())
+	r.GET("/poll/:id", func(c *gin.Context) {
+		if _, err := svc.Get(c.Request.Context(), c.Param("id")); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	exporter.Reset()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/poll/"+p.ID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub)
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	server, ok := byName["GET /poll/:id"]
+	if !ok {
+		t.Fatalf("missing request span, got %d spans", len(spans))
+	}
+	service, ok := byName["poll.Service.Get"]
+	if !ok {
+		t.Fatal("missing service span")
+	}
+	if service.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("service span is not a child of the request span")
+	}
+
+	var statements int
+	for _, s := range spans {
+		if s.Parent.SpanID() != service.SpanContext.SpanID() {
+			continue
+		}
+		for _, attr := range s.Attributes {
+			if attr.Key == "db.statement" && attr.Value.AsString() != "" {
+				statements++
+			}
+		}
+	}
+	if statements == 0 {
+		t.Error("expected SQL statement spans under the service span")
+	}
+}
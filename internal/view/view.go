@@ -11,6 +11,7 @@ func LoadTemplates(baseDir string) map[string]*template.Template {
 	tmplsDir := filepath.Join(baseDir, "web", "templates")
 	base := filepath.Join(tmplsDir, "layouts", "base.html")
 	partial := filepath.Join(tmplsDir, "partials", "vote_table.html")
+	spamFields := filepath.Join(tmplsDir, "partials", "spam_fields.html")
 
 	type page struct {
 		name     string
@@ -18,9 +19,12 @@ func LoadTemplates(baseDir string) map[string]*template.Template {
 	}
 	pages := []page{
 		{name: "index.html"},
-		{name: "new.html"},
-		{name: "poll.html", partials: []string{partial}},
+		{name: "new.html", partials: []string{spamFields}},
+		{name: "poll.html", partials: []string{partial, spamFields}},
 		{name: "admin.html", partials: []string{partial}},
+		{name: "unlock.html"},
+		{name: "operator.html"},
+		{name: "operator_poll.html"},
 		{name: "404.html"},
 	}
 
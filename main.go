@@ -3,38 +3,85 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"meetkat/internal/config"
 	"meetkat/internal/handler"
 	"meetkat/internal/i18n"
+	"meetkat/internal/listen"
+	"meetkat/internal/metrics"
 	"meetkat/internal/middleware"
 	"meetkat/internal/poll"
+	"meetkat/internal/secret"
 	"meetkat/internal/sqlite"
+	"meetkat/internal/tlscert"
+	"meetkat/internal/tracing"
 	"meetkat/internal/view"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Print(usage)
+		config.Usage(os.Stdout)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	if len(args) > 0 {
+		os.Exit(runCommand(cfg, args[0], args[1:]))
+	}
+
+	logger := newLogger(cfg)
+	slog.SetDefault(logger)
+
+	if cfg.TracingEnabled {
+		shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingEndpoint, cfg.TracingSampleRatio)
+		if err != nil {
+			log.Fatalf("init tracing: %v", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				slog.Warn("flush traces", "err", err)
+			}
+		}()
+	}
 
 	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0o750); err != nil {
 		log.Fatalf("create data directory: %v", err)
 	}
 
-	db, err := sqlite.Open(cfg.DBPath)
+	key, err := loadSecret(cfg)
+	if err != nil {
+		log.Fatalf("load secret key: %v", err)
+	}
+	db, err := openDatabase(cfg, key)
 	if err != nil {
 		log.Fatalf("open database: %v", err)
 	}
 	defer func() { _ = db.Close() }()
+	metrics.RegisterDB(db)
 
 	if err := os.Chmod(cfg.DBPath, 0o600); err != nil {
 		slog.Warn("could not set DB file permissions", "err", err)
@@ -45,65 +92,413 @@ func main() {
 		log.Fatalf("init i18n: %v", err)
 	}
 
-	repo := sqlite.NewPollRepository(db)
-	svc := poll.NewService(repo)
+	var repo poll.Repository = metrics.NewRepository(sqlite.NewPollRepository(db))
+	if cfg.CacheSize > 0 {
+		cache := poll.NewCachedRepository(repo, cfg.CacheSize, cfg.CacheTTL)
+		metrics.RegisterCache(func() (uint64, uint64, int) {
+			st := cache.Stats()
+			return st.Hits, st.Misses, st.Entries
+		})
+		defer func() {
+			st := cache.Stats()
+			slog.Info("poll cache stats", "hits", st.Hits, "misses", st.Misses, "entries", st.Entries)
+		}()
+		repo = cache
+	}
+	svc := newService(cfg, repo, key)
 	tmpls := view.LoadTemplates(".")
 	ph := handler.NewPollHandler(svc, tmpls)
 	hh := handler.NewHomeHandler(tmpls)
+	// Probes are unauthenticated: the migration check only reads the
+	// database, and at most once a minute. It needs no admin key as it never
+	// migrates.
+	migrator := sqlite.NewMigrator(db, nil)
+	health := handler.NewHealthHandler(
+		handler.HealthCheck{Name: "database", Check: db.PingContext},
+		handler.HealthCheck{Name: "migrations", Check: handler.CachedCheck(time.Minute, func(context.Context) error {
+			return migrator.Check()
+		})},
+		handler.HealthCheck{Name: "templates", Check: func(context.Context) error {
+			if len(tmpls) == 0 {
+				return errors.New("no templates loaded")
+			}
+			return nil
+		}},
+	)
+
+	gin.SetMode(cfg.GinMode)
 
-	if os.Getenv("GIN_MODE") == "" {
-		gin.SetMode(gin.ReleaseMode)
+	rateLimitAllow, err := middleware.ParseRateLimitAllowlist(cfg.RateLimitAllow)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	routeLimits := make(map[string]middleware.RateLimitPolicy)
+	for route, l := range cfg.RouteRateLimits() {
+		routeLimits[route] = middleware.RateLimitPolicy{PerMinute: l.PerMinute, Burst: l.Burst}
+	}
+	limits := middleware.NewRateLimits(cfg.BasePath, routeLimits, rateLimitAllow)
+	createLimit := limits.Limit(middleware.NewRateLimiter(cfg.RateLimitCreate, cfg.RateLimitCreateBurst))
+	voteLimit := limits.Limit(middleware.NewRateLimiter(cfg.RateLimitVote, cfg.RateLimitVoteBurst))
+	unlockLimit := limits.Limit(middleware.NewRateLimiter(cfg.RateLimitUnlock, cfg.RateLimitUnlockBurst))
+	reportLimit := limits.Limit(middleware.NewRateLimiter(cfg.RateLimitVote, cfg.RateLimitVoteBurst))
+	cookies := middleware.CookieConfig{
+		Secure:   cfg.CookieSecure,
+		SameSite: middleware.ParseSameSite(cfg.CookieSameSite),
+		Domain:   cfg.CookieDomain,
+		Path:     cfg.BasePath + "/",
 	}
+	ph.SetAccessCookies(secret.Derive(key, "poll-access"), cookies.Set)
+	spam := middleware.NewSpamGuard(middleware.SpamConfig{
+		Enabled:     cfg.SpamProtection,
+		Key:         secret.Derive(key, "form-token"),
+		MinFillTime: cfg.SpamMinFillTime,
+		MaxAge:      24 * time.Hour,
+		PoWBits:     cfg.SpamPoWBits,
+	})
 
-	createLimiter := middleware.NewRateLimiter(10, 10)
-	voteLimiter := middleware.NewRateLimiter(30, 30)
+	csrf := middleware.CSRFConfig{
+		Key:      secret.Derive(key, "csrf"),
+		Cookies:  cookies,
+		MaxAge:   24 * time.Hour,
+		BasePath: cfg.BasePath,
+		// Browsers send CSP violation reports without a token.
+		Exempt: []string{"POST /csp-report"},
+	}
+	if cfg.BaseURL != "" {
+		u, _ := url.Parse(cfg.BaseURL) // checked by Validate
+		csrf.TrustedOrigins = []string{u.Scheme + "://" + u.Host}
+	}
 
-	r := gin.Default()
-	r.Use(middleware.SecurityHeaders())
-	r.Use(middleware.CSRF())
-	r.Use(middleware.LangCookie(translator))
-	r.Static("/static", "./web/static")
-	r.GET("/sw.js", func(c *gin.Context) {
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	r := gin.New()
+	// Without trusted proxies, ClientIP is the peer address and
+	// X-Forwarded-For is ignored, so it cannot be spoofed to dodge rate limits.
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("trusted proxies: %v", err)
+	}
+	r.TrustedPlatform = cfg.PlatformHeader()
+	r.Use(middleware.RequestLogger(logger))
+	r.Use(middleware.Recovery())
+	// Probes are registered before the middleware below so they neither set
+	// cookies nor show up in request metrics.
+	r.GET("/healthz", health.Live)
+	r.GET("/readyz", health.Ready)
+	r.Use(middleware.Metrics())
+	r.Use(middleware.Tracing())
+	r.Use(middleware.Scheme(trustedProxies, cfg.TrustedPlatform != ""))
+	reportURI := cfg.BasePath + "/csp-report"
+	if cfg.BaseURL != "" {
+		reportURI = cfg.BaseURL + "/csp-report"
+	}
+	r.Use(middleware.SecurityHeaders(reportURI))
+	r.Use(limits.Middleware())
+	r.Use(middleware.CSRF(csrf))
+	r.Use(middleware.LangCookie(translator, cookies))
+	r.Use(middleware.BasePath(cfg.BasePath))
+	r.Use(middleware.BaseURL(cfg.BaseURL))
+
+	// All app routes live under the base path; the reverse proxy must pass
+	// it through unchanged.
+	app := r.Group(cfg.BasePath + "/")
+	app.Static("/static", "./web/static")
+	// sw.js is served from the base path so that its scope covers the app.
+	app.GET("/sw.js", func(c *gin.Context) {
 		c.File("./web/static/js/sw.js")
 	})
 
-	r.GET("/", hh.ShowHome)
-	r.GET("/new", ph.ShowNew)
-	r.POST("/new", createLimiter.Middleware(), ph.CreatePoll)
-	r.GET("/poll/:id", ph.ShowPoll)
-	r.POST("/poll/:id/vote", voteLimiter.Middleware(), ph.SubmitVote)
-	r.GET("/poll/:id/vote", func(c *gin.Context) {
-		c.Redirect(http.StatusSeeOther, "/poll/"+c.Param("id"))
+	app.GET("/", hh.ShowHome)
+	app.GET("/new", spam.Issue(), ph.ShowNew)
+	app.POST("/new", createLimit, spam.Protect(ph.RejectNewPoll), ph.CreatePoll)
+	app.GET("/poll/:id", spam.Issue(), ph.ShowPoll)
+	app.POST("/poll/:id/vote", voteLimit, spam.Protect(ph.RejectVote), ph.SubmitVote)
+	app.POST("/poll/:id/unlock", unlockLimit, ph.UnlockPoll)
+	app.GET("/poll/:id/vote", func(c *gin.Context) {
+		c.Redirect(http.StatusSeeOther, cfg.BasePath+"/poll/"+c.Param("id"))
 	})
-	r.GET("/poll/:id/admin", ph.ShowAdmin)
-	r.POST("/poll/:id/admin/vote", voteLimiter.Middleware(), ph.SubmitAdminVote)
-	r.GET("/poll/:id/admin/vote", func(c *gin.Context) {
-		c.Redirect(http.StatusSeeOther, "/poll/"+c.Param("id")+"/admin")
+	app.GET("/poll/:id/admin", ph.ShowAdmin)
+	app.POST("/poll/:id/admin/vote", voteLimit, ph.SubmitAdminVote)
+	app.GET("/poll/:id/admin/vote", func(c *gin.Context) {
+		c.Redirect(http.StatusSeeOther, cfg.BasePath+"/poll/"+c.Param("id")+"/admin")
+	})
+	app.POST("/poll/:id/admin/remove", voteLimit, ph.RemoveVote)
+	app.POST("/poll/:id/admin/delete", voteLimit, ph.DeletePoll)
+	app.POST("/poll/:id/admin/edit", voteLimit, ph.UpdateVote)
+	app.POST("/poll/:id/admin/rotate", voteLimit, ph.RotateAdminLink)
+	app.POST("/poll/:id/admin/close", voteLimit, ph.ClosePoll)
+	app.GET("/poll/:id/admin/export", ph.ExportPoll)
+	app.POST("/csp-report", reportLimit, handler.CSPReport)
+
+	var operatorSrv *http.Server
+	if cfg.OperatorEnabled() {
+		oh := handler.NewOperatorHandler(svc, tmpls)
+		var auth []gin.HandlerFunc
+		if cfg.OperatorPassword != "" {
+			auth = append(auth, middleware.OperatorAuth(cfg.OperatorPassword))
+		}
+		if cfg.OperatorAddr == "" {
+			operatorLimit := limits.Limit(middleware.NewRateLimiter(cfg.RateLimitVote, cfg.RateLimitVoteBurst))
+			operatorRoutes(app.Group("/operator", append([]gin.HandlerFunc{operatorLimit}, auth...)...), oh)
+		} else {
+			operatorSrv = &http.Server{
+				Addr:              cfg.OperatorAddr,
+				Handler:           operatorRouter(cfg, logger, translator, oh, auth, key),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			go func() {
+				if err := operatorSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Fatalf("operator listen: %v", err)
+				}
+			}()
+		}
+	}
+
+	var metricsSrv *http.Server
+	if cfg.MetricsEnabled {
+		if cfg.MetricsAddr == "" {
+			app.GET("/metrics", gin.WrapH(metrics.Handler()))
+		} else {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler())
+			metricsSrv = &http.Server{
+				Addr:              cfg.MetricsAddr,
+				Handler:           mux,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			go func() {
+				if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Fatalf("metrics listen: %v", err)
+				}
+			}()
+		}
+	}
+
+	// Checked once every route of the app router is registered, including
+	// /metrics, so that rate_limit_routes can name any of them. The probes
+	// are answered before the rate limiter runs, so naming them is an error
+	// rather than a limit that is never enforced.
+	limited := slices.DeleteFunc(r.Routes(), func(ri gin.RouteInfo) bool {
+		return ri.Path == "/healthz" || ri.Path == "/readyz"
 	})
-	r.POST("/poll/:id/admin/remove", voteLimiter.Middleware(), ph.RemoveVote)
-	r.POST("/poll/:id/admin/delete", voteLimiter.Middleware(), ph.DeletePoll)
-	r.POST("/poll/:id/admin/edit", voteLimiter.Middleware(), ph.UpdateVote)
+	if unknown := limits.Unknown(limited); len(unknown) > 0 {
+		log.Fatalf("rate_limit_routes: no such route: %s (the /healthz and /readyz probes cannot be limited)", strings.Join(unknown, ", "))
+	}
 
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	if cfg.BackupDir != "" && cfg.BackupInterval > 0 {
+		go sqlite.RunSnapshots(jobCtx, db, cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep)
+	}
+	if cfg.RetentionDays > 0 && cfg.RetentionInterval > 0 {
+		go svc.RunRetention(jobCtx, poll.RetentionPolicy{
+			MaxAge:    time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+			Basis:     cfg.RetentionBasis,
+			BatchSize: cfg.RetentionBatchSize,
+			DryRun:    cfg.RetentionDryRun,
+		}, cfg.RetentionInterval)
+	}
+
+	listeners, err := openListeners(cfg)
+	if err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+	warnUnixClientIP(listeners, trustedProxies, cfg.TrustedPlatform != "")
+	warnPoWWithoutHTTPS(cfg)
 	srv := &http.Server{
-		Addr:              ":" + cfg.Port,
 		Handler:           r,
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("listen: %v", err)
+	var redirectSrv *http.Server
+	if cfg.TLSCert != "" {
+		certs, err := tlscert.NewReloader(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			log.Fatalf("tls: %v", err)
 		}
-	}()
+		srv.TLSConfig = certs.TLSConfig()
+		if cfg.TLSWatchInterval > 0 {
+			go certs.Watch(jobCtx, cfg.TLSWatchInterval)
+		}
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := certs.Reload(); err != nil {
+					slog.Error("reload TLS certificate", "err", err)
+					continue
+				}
+				slog.Info("reloaded TLS certificate", "cert", cfg.TLSCert)
+			}
+		}()
+
+		if cfg.TLSRedirectAddr != "" {
+			redirectSrv = &http.Server{
+				Addr:              cfg.TLSRedirectAddr,
+				Handler:           tlscert.RedirectHandler(cfg.Port, cfg.BaseURL),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			go func() {
+				if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Fatalf("redirect listen: %v", err)
+				}
+			}()
+		}
+	}
+
+	for _, l := range listeners {
+		slog.Info("listening", "network", l.Addr().Network(), "addr", l.Addr().String(), "tls", srv.TLSConfig != nil)
+		go func() {
+			var err error
+			if srv.TLSConfig != nil {
+				// The certificate comes from TLSConfig.GetCertificate.
+				err = srv.ServeTLS(l, "", "")
+			} else {
+				err = srv.Serve(l)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("serve %s: %v", l.Addr(), err)
+			}
+		}()
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("shutting down...")
+	health.SetDraining()
+	if cfg.ShutdownDelay > 0 {
+		// Give load balancers time to observe the failing readiness probe.
+		time.Sleep(cfg.ShutdownDelay)
+	}
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("server forced to shutdown: %v", err)
 	}
+	if metricsSrv != nil {
+		_ = metricsSrv.Shutdown(ctx)
+	}
+	if operatorSrv != nil {
+		_ = operatorSrv.Shutdown(ctx)
+	}
+	if redirectSrv != nil {
+		_ = redirectSrv.Shutdown(ctx)
+	}
+}
+
+// operatorRoutes registers the operator console on g.
+func operatorRoutes(g *gin.RouterGroup, oh *handler.OperatorHandler) {
+	g.GET("", oh.ListPolls)
+	g.GET("/poll/:id", oh.ShowPoll)
+	g.POST("/poll/:id/delete", oh.DeletePoll)
+	g.POST("/poll/:id/remove", oh.RemoveVote)
+}
+
+// operatorRouter serves the operator console on its own listener, at
+// /operator without base path and outside the public rate limits.
+func operatorRouter(cfg config.Config, logger *slog.Logger, translator *i18n.Translator, oh *handler.OperatorHandler, auth []gin.HandlerFunc, key []byte) *gin.Engine {
+	cookies := middleware.CookieConfig{
+		Secure:   cfg.CookieSecure,
+		SameSite: middleware.ParseSameSite(cfg.CookieSameSite),
+		Path:     "/",
+	}
+	r := gin.New()
+	r.Use(middleware.RequestLogger(logger))
+	r.Use(middleware.Recovery())
+	r.Use(middleware.Scheme(nil, false))
+	r.Use(middleware.SecurityHeaders(""))
+	r.Use(middleware.CSRF(middleware.CSRFConfig{
+		Key:     secret.Derive(key, "csrf"),
+		Cookies: cookies,
+		MaxAge:  24 * time.Hour,
+	}))
+	r.Use(middleware.LangCookie(translator, cookies))
+	r.Use(middleware.BasePath(""))
+	r.Static("/static", "./web/static")
+	r.GET("/", func(c *gin.Context) {
+		c.Redirect(http.StatusSeeOther, "/operator")
+	})
+	operatorRoutes(r.Group("/operator", auth...), oh)
+	return r
+}
+
+// warnUnixClientIP warns when clients on a Unix socket cannot be told apart:
+// they all appear as listen.LocalIP, so unless the proxy in front is trusted
+// to name them, they share one rate limit bucket and spam guard identity.
+func warnUnixClientIP(listeners []net.Listener, trusted []netip.Prefix, trustPlatform bool) {
+	if trustPlatform || slices.ContainsFunc(trusted, func(p netip.Prefix) bool { return p.Contains(listen.LocalIP) }) {
+		return
+	}
+	for _, l := range listeners {
+		if l.Addr().Network() == "unix" {
+			slog.Warn("all clients on the Unix socket share one IP for rate limiting and spam protection; set trusted_proxies to 127.0.0.1 to use the proxy's X-Forwarded-For",
+				"socket", l.Addr().String())
+			return
+		}
+	}
+}
+
+// warnPoWWithoutHTTPS warns when browsers may be asked for a proof of work
+// over plain HTTP, where they lack the hashing API and every poll creation
+// and vote is rejected. Behind a proxy that terminates TLS, setting an https
+// base_url both silences the warning and is recommended anyway.
+func warnPoWWithoutHTTPS(cfg config.Config) {
+	if cfg.SpamPoWBits == 0 || cfg.TLSCert != "" || strings.HasPrefix(cfg.BaseURL, "https://") {
+		return
+	}
+	slog.Warn("spam_pow_bits needs HTTPS: browsers on plain HTTP cannot solve the proof of work, so their polls and votes are rejected; serve TLS or set an https base_url",
+		"spam_pow_bits", cfg.SpamPoWBits)
+}
+
+// openListeners returns the sockets passed by systemd socket activation or,
+// without those, the configured TCP port and Unix socket.
+func openListeners(cfg config.Config) ([]net.Listener, error) {
+	listeners, err := listen.Systemd()
+	if err != nil {
+		return nil, fmt.Errorf("systemd sockets: %w", err)
+	}
+	if len(listeners) > 0 {
+		return listeners, nil
+	}
+
+	if cfg.Port != "" {
+		l, err := net.Listen("tcp", ":"+cfg.Port)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	if cfg.UnixSocket != "" {
+		l, err := listen.Unix(cfg.UnixSocket, cfg.SocketMode())
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	if len(listeners) == 0 {
+		return nil, errors.New("no listener: set port or unix_socket, or start via systemd socket activation")
+	}
+	return listeners, nil
+}
+
+// newLogger builds the application logger from the configured format and
+// level.
+func newLogger(cfg config.Config) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
 }
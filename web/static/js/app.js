@@ -149,7 +149,12 @@ function initVoteButtons(root) {
 function initSubmitButton(form) {
     var nameInput = form.querySelector('#vote-name');
     var btn = form.querySelector('#vote-submit');
-    if (!nameInput || !btn) return;
+    if (!btn) return;
+    // Polls that do not keep names have no name field.
+    if (!nameInput) {
+        btn.disabled = false;
+        return;
+    }
 
     function update() {
         var hasName = nameInput.value.trim() !== '';
@@ -174,8 +179,7 @@ function initSubmitButton(form) {
 
 // Check whether the inline vote row has any unanswered options.
 function voteRowHasEmpty(form) {
-    var nameInput = form.querySelector('#vote-name');
-    var row = nameInput ? nameInput.closest('tr') : null;
+    var row = form.querySelector('tr[data-vote-row]');
     if (!row) return false;
     var inputs = row.querySelectorAll('input[type="hidden"][name^="vote-"]');
     var empty = false;
@@ -218,6 +222,83 @@ function initTable() {
     });
 }
 
+// Copy buttons: data-copy-from names the input whose value is copied.
+// The clipboard API is missing on plain HTTP; when copying fails, the input
+// is selected for copying by hand and the button keeps its text.
+document.addEventListener('click', function (e) {
+    var btn = e.target.closest('[data-copy-from]');
+    if (!btn) return;
+    var input = document.getElementById(btn.dataset.copyFrom);
+    if (!input) return;
+    if (!navigator.clipboard) {
+        input.select();
+        return;
+    }
+    navigator.clipboard.writeText(input.value).then(function () {
+        btn.textContent = btn.dataset.copiedText;
+        setTimeout(function () { btn.textContent = btn.dataset.copyText; }, 2000);
+    }, function () {
+        input.select();
+    });
+});
+
+// Forms with data-confirm ask before submitting
+document.addEventListener('submit', function (e) {
+    var msg = e.target.dataset && e.target.dataset.confirm;
+    if (msg && !confirm(msg)) e.preventDefault();
+});
+
+// Proof of work for spam protection: find a nonce such that
+// SHA-256(token + ":" + nonce) starts with `bits` zero bits.
+// crypto.subtle is only available on HTTPS (and localhost).
+function solveWork(token, bits) {
+    var enc = new TextEncoder();
+    function zeroBits(buf) {
+        var bytes = new Uint8Array(buf), n = 0;
+        for (var i = 0; i < bytes.length; i++) {
+            if (bytes[i] === 0) { n += 8; continue; }
+            n += Math.clz32(bytes[i]) - 24;
+            break;
+        }
+        return n;
+    }
+    function attempt(nonce) {
+        return crypto.subtle.digest('SHA-256', enc.encode(token + ':' + nonce)).then(function (buf) {
+            return zeroBits(buf) >= bits ? String(nonce) : attempt(nonce + 1);
+        });
+    }
+    return attempt(0);
+}
+
+// Fill in the proof of work of a form rendered with the spam_fields partial.
+// Resolves immediately when the server does not ask for one. It never
+// rejects: without crypto.subtle (plain HTTP) the form is sent without a
+// proof, and the server rejects it with its usual error instead of the
+// submit silently doing nothing.
+function prepareSpamFields(form) {
+    var token = form.querySelector('input[name="form_token"]');
+    var pow = form.querySelector('input[name="pow"]');
+    var bits = pow ? parseInt(pow.dataset.powBits, 10) || 0 : 0;
+    if (!token || bits <= 0 || pow.value) return Promise.resolve();
+    if (!(window.crypto && crypto.subtle)) {
+        console.error('proof of work needs HTTPS; submitting without it');
+        return Promise.resolve();
+    }
+    return solveWork(token.value, bits).then(function (nonce) { pow.value = nonce; }, function (err) {
+        console.error('proof of work failed; submitting without it', err);
+    });
+}
+
+// Forms posted without fetch (new poll): solve before the real submit
+document.querySelectorAll('form:not([data-confirm-incomplete]) input[name="pow"]').forEach(function (pow) {
+    var form = pow.form;
+    form.addEventListener('submit', function (e) {
+        if (pow.value || !(parseInt(pow.dataset.powBits, 10) > 0)) return;
+        e.preventDefault();
+        prepareSpamFields(form).then(function () { form.submit(); });
+    });
+});
+
 // AJAX fetch interceptor for vote operations
 (function () {
     var inFlight = false;
@@ -234,6 +315,20 @@ function initTable() {
             headers: { 'X-Requested-With': 'fetch', 'X-CSRF-Token': csrfToken },
             body: formData
         }).then(function (res) {
+            // CSRF tokens expire; every response brings a fresh one
+            var newCsrf = res.headers.get('X-CSRF-Token');
+            if (newCsrf) {
+                var meta = document.querySelector('meta[name="csrf-token"]');
+                if (meta) meta.content = newCsrf;
+                document.querySelectorAll('input[name="csrf_token"]').forEach(function (input) { input.value = newCsrf; });
+            }
+            // Form tokens are single-use; take the fresh one for the next vote,
+            // which rejected votes bring as well
+            var formToken = res.headers.get('X-Form-Token');
+            if (formToken) {
+                document.querySelectorAll('input[name="form_token"]').forEach(function (input) { input.value = formToken; });
+                document.querySelectorAll('input[name="pow"]').forEach(function (input) { input.value = ''; });
+            }
             if (!res.ok) throw new Error(res.statusText);
             return res.text();
         }).then(function (html) {
@@ -276,17 +371,25 @@ function initTable() {
             var formData = new FormData();
             var nameInput = form.querySelector('#vote-name');
             if (nameInput) formData.append('name', nameInput.value);
-            var voteRow = nameInput ? nameInput.closest('tr') : null;
+            var voteRow = form.querySelector('tr[data-vote-row]');
             if (voteRow) {
                 voteRow.querySelectorAll('input[type="hidden"][name^="vote-"]').forEach(function (input) {
                     formData.append(input.name, input.value);
                 });
             }
-            fetchAndSwap(form.action, formData);
+            prepareSpamFields(form).then(function () {
+                ['form_token', 'pow', 'website'].forEach(function (name) {
+                    var input = form.querySelector('input[name="' + name + '"]');
+                    if (input) formData.append(name, input.value);
+                });
+                fetchAndSwap(form.action, formData);
+            });
             // Clear the name input and reset submit button after successful vote
-            if (nameInput) nameInput.value = '';
-            var submitBtn = form.querySelector('#vote-submit');
-            if (submitBtn) submitBtn.disabled = true;
+            if (nameInput) {
+                nameInput.value = '';
+                var submitBtn = form.querySelector('#vote-submit');
+                if (submitBtn) submitBtn.disabled = true;
+            }
         });
     });
 
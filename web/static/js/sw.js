@@ -1,8 +1,9 @@
-const CACHE_NAME = 'meetkat-v1';
+const CACHE_NAME = 'meetkat-v2';
+// Relative to this script so the app also works under a base path.
 const PRECACHE_URLS = [
-  '/static/css/style.css',
-  '/static/js/app.js',
-  '/static/icons/meetkot-head.svg'
+  'static/css/style.css',
+  'static/js/app.js',
+  'static/icons/meetkot-head.svg'
 ];
 
 self.addEventListener('install', (event) => {